@@ -1,106 +1,173 @@
 package main
 
 import (
+	"bufio"
+	"coursera/hw1_tree/tree"
+	"flag"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
-	"strconv"
 )
 
+// options of the command, besides the ones of the tree itself.
+type options struct {
+	tree.Options
+	format string // One of text, json, xml or html.
+	style  string // Graphics of the text format: unicode, ascii or indent.
+}
+
+// renderers make the text renderers by the names of their styles.
+var renderers = map[string]func(opts tree.Options) *tree.LineRenderer{
+	"unicode": tree.NewUnicodeRenderer,
+	"ascii":   tree.NewASCIIRenderer,
+	"indent":  tree.NewIndentRenderer,
+}
+
+// usages of the commands, the tree one is the default.
+var usages = map[string]string{
+	"tree":     "usage go run . <path|archive> [-f] [-l] [-L level] [-I pattern] [-P pattern] [--gitignore] [-o format] [--style style] [--du] [-h] [--sort type] [--dirsfirst] [-p] [-u] [-D] [--hash] [-j n]",
+	"diff":     "usage go run . diff <path|snapshot> <path|snapshot> [-o format] [-h] [tree flags]",
+	"snapshot": "usage go run . snapshot <path> <snapshot.json> [tree flags]",
+}
+
 func main() {
-	out := os.Stdout
-	if !(len(os.Args) == 2 || len(os.Args) == 3) {
-		panic("usage go run main.go . [-f]")
+	command, args := "tree", os.Args[1:]
+	if len(args) > 0 && usages[args[0]] != "" && args[0] != "tree" {
+		command, args = args[0], args[1:]
 	}
-	path := os.Args[1]
-	printFiles := len(os.Args) == 3 && os.Args[2] == "-f"
-	err := dirTree(out, path, printFiles)
-	if err != nil {
-		panic(err.Error())
+
+	opts := options{}
+	flags := newFlagSet(command, &opts)
+	paths := parseArgs(flags, args)
+
+	usageError := func(format string, args ...interface{}) {
+		fmt.Fprintf(flags.Output(), format+"\n", args...)
+		flags.Usage()
+		os.Exit(2)
 	}
-}
 
-func dirTree(out io.Writer, path string, printFiles bool) error {
-	offset := [2]int{-1, -1}
-	str, err := scanDir("", path, 1, offset, printFiles)
+	if _, ok := tree.Formats[opts.format]; !ok && opts.format != "text" {
+		usageError("unknown output format %q", opts.format)
+	}
 
-	if err != nil {
-		fmt.Println("Error: " + err.Error())
+	if _, ok := renderers[opts.style]; !ok {
+		usageError("unknown style %q", opts.style)
 	}
 
-	_, _ = fmt.Fprint(out, str)
+	if _, ok := tree.Sorts[opts.Sort]; !ok {
+		usageError("unknown sort type %q", opts.Sort)
+	}
 
-	return nil
-}
+	expected := map[string]int{"tree": 1, "diff": 2, "snapshot": 2}[command]
+	if len(paths) != expected {
+		usageError("expected %d paths, got %d", expected, len(paths))
+	}
 
-func scanDir(str string, path string, lvl int, offset [2]int, printFiles bool) (string, error) {
-	dir, err := ioutil.ReadDir(path)
-	subLvl := lvl + 1
+	// Buffer the output so huge trees don't cost a syscall per line.
+	out := bufio.NewWriter(os.Stdout)
+	var err error
+
+	switch command {
+	case "diff":
+		err = diffTree(out, paths[0], paths[1], opts)
+	case "snapshot":
+		err = tree.SaveSnapshot(paths[0], paths[1], opts.Options)
+	default:
+		err = dirTreeOpts(out, paths[0], opts)
+	}
 
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
 	if err != nil {
-		return "", err
+		panic(err.Error())
 	}
+}
 
-	if !printFiles {
-		dir = filterFiles(dir)
+func newFlagSet(command string, opts *options) *flag.FlagSet {
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.BoolVar(&opts.PrintFiles, "f", false, "print files")
+	flags.IntVar(&opts.MaxDepth, "L", 0, "descend only `level` directories deep")
+	flags.Var(&opts.Exclude, "I", "do not list entries that match the `pattern`")
+	flags.Var(&opts.Include, "P", "list only those files that match the `pattern`")
+	flags.BoolVar(&opts.GitIgnore, "gitignore", false, "filter entries by .gitignore files")
+	flags.BoolVar(&opts.FollowLinks, "l", false, "follow symbolic links to directories")
+	flags.StringVar(&opts.format, "o", "text", "output `format`: text, json, xml or html")
+	flags.StringVar(&opts.style, "style", "unicode", "graphics of the text output: unicode, ascii or indent")
+	flags.BoolVar(&opts.DiskUsage, "du", false, "print the size of each directory as the sum of its contents")
+	flags.BoolVar(&opts.Human, "h", false, "print the sizes in a human readable way")
+	flags.StringVar(&opts.Sort, "sort", "name", "sort the entries by `type`: name, size, mtime or ext")
+	flags.BoolVar(&opts.DirsFirst, "dirsfirst", false, "list directories before files")
+	flags.BoolVar(&opts.Perms, "p", false, "print the permissions of each entry")
+	flags.BoolVar(&opts.Owner, "u", false, "print the owner of each entry")
+	flags.BoolVar(&opts.ModTime, "D", false, "print the modification time of each entry")
+	flags.BoolVar(&opts.Hash, "hash", false, "print the SHA-256 hash of each file")
+	flags.IntVar(&opts.Workers, "j", 1, "read up to `n` directories in parallel")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), usages[command])
+		flags.PrintDefaults()
 	}
 
-	for idx, file := range dir {
-		isLast := idx == len(dir)-1
+	return flags
+}
 
-		for step := 0; step < lvl-1; step++ {
-			if step >= offset[0] && step <= offset[1] {
-				str += "	"
-			} else {
-				str += "│	"
-			}
-		}
+// parseArgs returns the positional arguments, the flags are accepted
+// both before and after them, e.g. "go run . testdata -f".
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	paths := make([]string, 0)
 
-		if isLast {
-			str += "└───"
-
-			if file.IsDir() {
-				if offset[0] == -1 {
-					offset[0] = lvl - 1
-				}
-				if offset[1] < lvl {
-					offset[1] = lvl - 1
-				}
-			}
-		} else {
-			str += "├───"
+	for {
+		_ = flags.Parse(args)
+
+		if flags.NArg() == 0 {
+			return paths
 		}
 
-		if file.IsDir() {
-			str += file.Name() + "\n"
-			str, err = scanDir(str, path+"/"+file.Name(), subLvl, offset, printFiles)
+		paths = append(paths, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
 
-			if err != nil {
-				return "", err
-			}
-		} else if printFiles {
-			size := "empty"
+func dirTree(out io.Writer, path string, printFiles bool) error {
+	return dirTreeOpts(out, path, options{Options: tree.Options{PrintFiles: printFiles}})
+}
 
-			if file.Size() > 0 {
-				size = strconv.FormatInt(file.Size(), 10) + "b"
-			}
+func dirTreeOpts(out io.Writer, path string, opts options) error {
+	if opts.format == "" || opts.format == "text" {
+		return tree.Print(out, path, opts.Options, newRenderer(opts))
+	}
 
-			str += file.Name() + " (" + size + ")" + "\n"
-		}
+	root, err := tree.Build(path, opts.Options)
+	if err != nil {
+		return err
 	}
 
-	return str, nil
+	return tree.WriteFormat(out, root, opts.format)
 }
 
-func filterFiles(dir []os.FileInfo) []os.FileInfo {
-	tmpDir := make([]os.FileInfo, 0)
+// diffTree prints the entries which were added, removed or changed in size
+// between the old and the new trees, each of them is a directory or a snapshot.
+func diffTree(out io.Writer, oldPath, newPath string, opts options) error {
+	diff, err := tree.DiffPaths(oldPath, newPath, opts.Options)
+	if err != nil {
+		return err
+	}
 
-	for _, file := range dir {
-		if file.IsDir() {
-			tmpDir = append(tmpDir, file)
-		}
+	if opts.format == "" || opts.format == "text" {
+		// The sizes of the directories are always counted to compare.
+		opts.DiskUsage = true
+
+		return tree.Render(out, diff, newRenderer(opts))
+	}
+
+	return tree.WriteFormat(out, diff, opts.format)
+}
+
+func newRenderer(opts options) tree.Renderer {
+	newStyle, ok := renderers[opts.style]
+	if !ok {
+		newStyle = tree.NewUnicodeRenderer
 	}
 
-	return tmpDir
+	return newStyle(opts.Options)
 }
@@ -2,6 +2,10 @@ package main
 
 import (
 	"bytes"
+	"coursera/hw1_tree/tree"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -73,3 +77,123 @@ func TestTreeDir(t *testing.T) {
 		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDirResult)
 	}
 }
+
+func TestTreeError(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := dirTree(out, "testdata/missing", true)
+	if err == nil {
+		t.Errorf("test for error Failed - expected error for missing path")
+	}
+}
+
+const testJSONResult = `{
+  "name": "testdata/zline",
+  "type": "directory",
+  "size": 0,
+  "children": [
+    {
+      "name": "empty.txt",
+      "type": "file",
+      "size": 0
+    },
+    {
+      "name": "lorem",
+      "type": "directory",
+      "size": 0,
+      "children": []
+    }
+  ]
+}
+`
+
+const testXMLResult = `<?xml version="1.0" encoding="UTF-8"?>
+<tree>
+  <directory name="testdata/zline" size="0">
+    <file name="empty.txt" size="0"></file>
+    <directory name="lorem" size="0"></directory>
+  </directory>
+</tree>
+`
+
+func TestTreeFormats(t *testing.T) {
+	for format, expected := range map[string]string{"json": testJSONResult, "xml": testXMLResult} {
+		out := new(bytes.Buffer)
+		err := dirTreeOpts(out, "testdata/zline", options{Options: tree.Options{PrintFiles: true, MaxDepth: 1}, format: format})
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", format, err)
+		}
+		result := out.String()
+		if result != expected {
+			t.Errorf("[%s] results not match\nGot:\n%v\nExpected:\n%v", format, result, expected)
+		}
+	}
+}
+
+func TestTreeParallel(t *testing.T) {
+	for _, printFiles := range []bool{true, false} {
+		expected := testDirResult
+		if printFiles {
+			expected = testFullResult
+		}
+
+		out := new(bytes.Buffer)
+		err := dirTreeOpts(out, "testdata", options{Options: tree.Options{PrintFiles: printFiles, Workers: 4}})
+		if err != nil {
+			t.Errorf("test for OK Failed - error")
+		}
+		result := out.String()
+		if result != expected {
+			t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+		}
+	}
+}
+
+const testDiffResult = `├───- gone.txt (1b)
+├───~ keep.txt (3b -> 5b)
+├───+ new.txt (empty)
+└───+ newdir (2b)
+`
+
+func TestTreeDiff(t *testing.T) {
+	root, err := ioutil.TempDir("", "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"old/dir/same.txt": "1",
+		"old/gone.txt":     "1",
+		"old/keep.txt":     "123",
+		"new/dir/same.txt": "1",
+		"new/keep.txt":     "12345",
+		"new/new.txt":      "",
+		"new/newdir/a.txt": "12",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshot := filepath.Join(root, "old.json")
+	if err := tree.SaveSnapshot(filepath.Join(root, "old"), snapshot, tree.Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, oldPath := range []string{filepath.Join(root, "old"), snapshot} {
+		out := new(bytes.Buffer)
+		err := diffTree(out, oldPath, filepath.Join(root, "new"), options{})
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", oldPath, err)
+		}
+		result := out.String()
+		if result != testDiffResult {
+			t.Errorf("[%s] results not match\nGot:\n%v\nExpected:\n%v", oldPath, result, testDiffResult)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package tree
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	errNotDir  = errors.New("not a directory")
+	errNotLink = errors.New("not a symlink")
+)
+
+// IsArchive reports whether the path is a zip, tar or tar.gz archive judging by its extension.
+func IsArchive(path string) bool {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(strings.ToLower(path), ext) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Archive is the read-only file system of a zip, tar or tar.gz archive.
+// The path of the archive itself stands for its root directory,
+// so the archive is walked in the same way as a directory.
+type Archive struct {
+	root     string
+	files    map[string]*archiveFile // By the slash separated path inside of the archive.
+	children map[string][]string     // Paths of the entries of each directory.
+	closer   io.Closer
+	hashTar  bool // Hash the tar files by the index pass.
+}
+
+type archiveFile struct {
+	info os.FileInfo
+	link string
+	hash string // SHA-256 of the contents if it's known by the index pass.
+	open func() (io.ReadCloser, error)
+}
+
+// OpenArchive reads the index of the archive, the files contents are read only when opened.
+func OpenArchive(archivePath string) (*Archive, error) {
+	return openArchive(archivePath, false)
+}
+
+// openArchive is OpenArchive which also hashes the files of a tar by the index pass
+// if the hash is set, since every open of a big one reads the archive from the start.
+func openArchive(archivePath string, hash bool) (*Archive, error) {
+	a := &Archive{
+		root:     filepath.ToSlash(filepath.Clean(archivePath)),
+		files:    map[string]*archiveFile{".": {info: dirInfo(path.Base(archivePath))}},
+		children: make(map[string][]string),
+		hashTar:  hash,
+	}
+
+	var err error
+
+	if strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		err = a.readZip(archivePath)
+	} else {
+		err = a.readTar(archivePath)
+	}
+
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *Archive) readZip(archivePath string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	a.closer = reader
+
+	for _, file := range reader.File {
+		file := file
+		entry := &archiveFile{info: file.FileInfo(), open: func() (io.ReadCloser, error) {
+			return file.Open()
+		}}
+
+		// The target of a symlink is stored as its contents.
+		if entry.info.Mode()&os.ModeSymlink != 0 {
+			contents, err := file.Open()
+			if err != nil {
+				return err
+			}
+			target, err := ioutil.ReadAll(contents)
+			contents.Close()
+			if err != nil {
+				return err
+			}
+			entry.link = string(target)
+		}
+
+		a.add(file.Name, entry)
+	}
+
+	return nil
+}
+
+// Limits of the contents of the tar files kept in memory by the index pass.
+// The bigger files are read by scanning the archive again when opened.
+const (
+	tarCacheFile  = 1 << 20
+	tarCacheTotal = 64 << 20
+)
+
+func (a *Archive) readTar(archivePath string) error {
+	cached := int64(0)
+
+	return scanTar(archivePath, func(header *tar.Header, contents io.Reader) (bool, error) {
+		name := header.Name
+		entry := &archiveFile{info: header.FileInfo(), link: header.Linkname, open: func() (io.ReadCloser, error) {
+			return openTarFile(archivePath, name)
+		}}
+
+		if header.Typeflag != tar.TypeSymlink {
+			entry.link = ""
+		}
+
+		hash := sha256.New()
+		if a.hashTar && header.Typeflag == tar.TypeReg {
+			contents = io.TeeReader(contents, hash)
+		}
+
+		// A tar can't be read at random, so the small files are kept while the archive
+		// is read anyway, e.g. the ones to hash or the .gitignore ones.
+		if header.Typeflag == tar.TypeReg && header.Size <= tarCacheFile && cached+header.Size <= tarCacheTotal {
+			data, err := ioutil.ReadAll(contents)
+			if err != nil {
+				return false, err
+			}
+
+			cached += header.Size
+			entry.open = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(data)), nil
+			}
+		}
+
+		if a.hashTar && header.Typeflag == tar.TypeReg {
+			// The rest of the big file is read only to be hashed.
+			if _, err := io.Copy(ioutil.Discard, contents); err != nil {
+				return false, err
+			}
+
+			entry.hash = hex.EncodeToString(hash.Sum(nil))
+		}
+
+		a.add(name, entry)
+
+		return false, nil
+	})
+}
+
+// openTar opens the plain or gzipped tar archive, the closer closes the archive file.
+func openTar(archivePath string) (*tar.Reader, io.Closer, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if strings.HasSuffix(strings.ToLower(archivePath), ".tar") {
+		return tar.NewReader(file), file, nil
+	}
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return tar.NewReader(gz), file, nil
+}
+
+// scanTar calls the visit for every entry of the plain or gzipped tar archive until it returns true.
+func scanTar(archivePath string, visit func(header *tar.Header, contents io.Reader) (bool, error)) error {
+	archive, closer, err := openTar(archivePath)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if done, err := visit(header, archive); done || err != nil {
+			return err
+		}
+	}
+}
+
+// openTarFile reads the archive once again up to the file since a tar can't be read at random,
+// it's used only for the files too big to be kept by readTar. The contents are streamed
+// from the archive, which is closed with the file.
+func openTarFile(archivePath, name string) (io.ReadCloser, error) {
+	archive, closer, err := openTar(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			closer.Close()
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		if err != nil {
+			closer.Close()
+			return nil, err
+		}
+
+		if header.Name == name {
+			return tarFile{Reader: archive, Closer: closer}, nil
+		}
+	}
+}
+
+// tarFile is the contents of a file read right from the archive.
+type tarFile struct {
+	io.Reader
+	io.Closer
+}
+
+// add puts the file into the index making all its parent directories
+// which are not stored in the archive explicitly.
+func (a *Archive) add(name string, file *archiveFile) {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		return
+	}
+
+	if _, exists := a.files[name]; !exists {
+		parent := path.Dir(name)
+		a.addDir(parent)
+		a.children[parent] = append(a.children[parent], name)
+	}
+
+	// An explicit entry replaces the made directory.
+	a.files[name] = file
+}
+
+func (a *Archive) addDir(name string) {
+	if _, exists := a.files[name]; !exists {
+		a.add(name, &archiveFile{info: dirInfo(path.Base(name))})
+	}
+}
+
+// name returns the path inside of the archive by the one joined to the archive path.
+func (a *Archive) name(filePath string) string {
+	name := filepath.ToSlash(filepath.Clean(filePath))
+
+	if name == a.root {
+		return "."
+	}
+
+	return strings.TrimPrefix(name, a.root+"/")
+}
+
+func (a *Archive) lookup(op, filePath string) (*archiveFile, error) {
+	file, ok := a.files[a.name(filePath)]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: filePath, Err: os.ErrNotExist}
+	}
+
+	return file, nil
+}
+
+func (a *Archive) ReadDir(dirPath string) ([]os.FileInfo, error) {
+	dir, err := a.lookup("open", dirPath)
+	if err != nil {
+		return nil, err
+	}
+	if !dir.info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: dirPath, Err: errNotDir}
+	}
+
+	children := a.children[a.name(dirPath)]
+	infos := make([]os.FileInfo, 0, len(children))
+
+	for _, child := range children {
+		infos = append(infos, a.files[child].info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name() < infos[j].Name()
+	})
+
+	return infos, nil
+}
+
+// Stat doesn't follow the symlinks inside of the archive, so they are never walked into.
+func (a *Archive) Stat(filePath string) (os.FileInfo, error) {
+	file, err := a.lookup("stat", filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return file.info, nil
+}
+
+func (a *Archive) Readlink(filePath string) (string, error) {
+	file, err := a.lookup("readlink", filePath)
+	if err != nil {
+		return "", err
+	}
+	if file.info.Mode()&os.ModeSymlink == 0 {
+		return "", &os.PathError{Op: "readlink", Path: filePath, Err: errNotLink}
+	}
+
+	return file.link, nil
+}
+
+func (a *Archive) Open(filePath string) (io.ReadCloser, error) {
+	file, err := a.lookup("open", filePath)
+	if err != nil {
+		return nil, err
+	}
+	if file.open == nil {
+		return nil, &os.PathError{Op: "open", Path: filePath, Err: errors.New("is a directory")}
+	}
+
+	return file.open()
+}
+
+// hash returns the SHA-256 of the file if it's known by the index pass.
+func (a *Archive) hash(filePath string) (string, bool) {
+	file, err := a.lookup("open", filePath)
+	if err != nil || file.hash == "" {
+		return "", false
+	}
+
+	return file.hash, true
+}
+
+func (a *Archive) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+
+	return a.closer.Close()
+}
+
+// dirInfo describes a directory which isn't stored in the archive on its own.
+type dirInfo string
+
+func (d dirInfo) Name() string       { return string(d) }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() interface{}   { return nil }
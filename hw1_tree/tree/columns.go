@@ -0,0 +1,100 @@
+package tree
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+const timeLayout = "2006-01-02 15:04"
+
+// describe fills the optional metadata columns of the node on the level.
+func (w *walker) describe(n *Node, file entry, path string, lvl int) {
+	if w.opts.Perms {
+		n.Mode = file.Mode().String()
+	}
+
+	if w.opts.Owner {
+		n.Owner = w.ownerName(file)
+	}
+
+	if w.opts.ModTime || w.opts.Sort == "mtime" {
+		n.ModTime = file.ModTime()
+	}
+
+	// The files read only to count the sizes are dropped by prune, so they aren't hashed.
+	if w.opts.Hash && file.Mode().IsRegular() && w.listed(file, lvl) {
+		hash, err := hashFile(w.fs, path)
+		if err != nil {
+			n.Err = errorText(err)
+		}
+		n.Hash = hash
+	}
+}
+
+// ownerName returns the name of the user owning the file or its id if the user is unknown.
+// The names are cached since a tree usually has just a few owners.
+func (w *walker) ownerName(file entry) string {
+	uid, ok := fileOwner(file)
+	if !ok {
+		return ""
+	}
+
+	if name, ok := w.owners[uid]; ok {
+		return name
+	}
+
+	name := lookupUser(uid)
+
+	if w.owners == nil {
+		w.owners = make(map[string]string)
+	}
+	w.owners[uid] = name
+
+	return name
+}
+
+func hashFile(fs FileSystem, path string) (string, error) {
+	if archive, ok := fs.(*Archive); ok {
+		if hash, ok := archive.hash(path); ok {
+			return hash, nil
+		}
+	}
+
+	file, err := fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// columns returns the metadata of the node to print in front of its name.
+func columns(n *Node, opts *Options) string {
+	values := make([]string, 0, 4)
+
+	if opts.Perms {
+		values = append(values, n.Mode)
+	}
+
+	if opts.Owner {
+		values = append(values, n.Owner)
+	}
+
+	if opts.ModTime && !n.ModTime.IsZero() {
+		values = append(values, n.ModTime.Format(timeLayout))
+	}
+
+	if n.Hash != "" {
+		values = append(values, n.Hash)
+	}
+
+	return strings.Join(values, " ")
+}
@@ -0,0 +1,153 @@
+package tree
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Changes of the entries found by Diff.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// changeMarks are printed in front of the names of the changed entries.
+var changeMarks = map[string]string{
+	ChangeAdded:   "+ ",
+	ChangeRemoved: "- ",
+	ChangeChanged: "~ ",
+}
+
+// DiffPaths compares the trees of the paths, each of them is a directory or a snapshot.
+// The result holds the new tree with only the entries which were added, removed or changed in size.
+func DiffPaths(oldPath, newPath string, opts Options) (*Node, error) {
+	// All the files are compared and the directories sizes show how much the subtrees grew,
+	// the merge of the children relies on the sorting by name.
+	opts.PrintFiles, opts.DiskUsage, opts.Sort, opts.DirsFirst = true, true, "name", false
+
+	oldRoot, err := load(oldPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	newRoot, err := load(newPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return Diff(oldRoot, newRoot), nil
+}
+
+// SaveSnapshot writes the tree of the path with all its files into the JSON file to diff against later.
+func SaveSnapshot(path, snapshotPath string, opts Options) error {
+	opts.PrintFiles, opts.DiskUsage, opts.Sort, opts.DirsFirst = true, true, "name", false
+
+	root, err := Build(path, opts)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(snapshotPath)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteJSON(file, root); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// load walks the directory or reads the snapshot if the path is a file.
+func load(path string, opts Options) (*Node, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() || IsArchive(path) {
+		return Build(path, opts)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	root := &Node{}
+	if err := json.NewDecoder(file).Decode(root); err != nil {
+		return nil, err
+	}
+
+	root.restore()
+	root.sumSizes()
+	root.sort(Sorts["name"])
+
+	return root, nil
+}
+
+// restore sets the fields of the decoded snapshot which are not saved.
+func (n *Node) restore() {
+	n.dir = n.Type == TypeDir || n.Children != nil
+
+	for _, child := range n.Children {
+		child.restore()
+	}
+}
+
+// Diff returns the new node with only those children which differ from the old ones,
+// the children of both nodes have to be sorted by name.
+func Diff(oldNode, newNode *Node) *Node {
+	result := *newNode
+	result.Children = []*Node{}
+
+	if oldNode.Size != newNode.Size {
+		result.Change = ChangeChanged
+		result.OldSize = oldNode.Size
+	}
+
+	oldChildren, newChildren := oldNode.Children, newNode.Children
+
+	for len(oldChildren) > 0 || len(newChildren) > 0 {
+		switch {
+		case len(newChildren) == 0 || (len(oldChildren) > 0 && oldChildren[0].Name < newChildren[0].Name):
+			result.Children = append(result.Children, markNode(oldChildren[0], ChangeRemoved))
+			oldChildren = oldChildren[1:]
+		case len(oldChildren) == 0 || newChildren[0].Name < oldChildren[0].Name:
+			result.Children = append(result.Children, markNode(newChildren[0], ChangeAdded))
+			newChildren = newChildren[1:]
+		default:
+			oldChild, newChild := oldChildren[0], newChildren[0]
+			oldChildren, newChildren = oldChildren[1:], newChildren[1:]
+
+			if oldChild.Type != newChild.Type || oldChild.Target != newChild.Target {
+				changed := markNode(newChild, ChangeChanged)
+				changed.OldSize = oldChild.Size
+				result.Children = append(result.Children, changed)
+				continue
+			}
+
+			if diff := Diff(oldChild, newChild); diff.Change != "" || len(diff.Children) > 0 {
+				result.Children = append(result.Children, diff)
+			}
+		}
+	}
+
+	return &result
+}
+
+// markNode copies the node without the contents since the whole subtree has the same change.
+func markNode(n *Node, change string) *Node {
+	marked := *n
+	marked.Change = change
+
+	if marked.Children != nil {
+		marked.Children = []*Node{}
+	}
+
+	return &marked
+}
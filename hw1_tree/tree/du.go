@@ -0,0 +1,63 @@
+package tree
+
+import (
+	"fmt"
+)
+
+var sizeUnits = []string{"K", "M", "G", "T", "P", "E"}
+
+// humanSize Formats the size with a single decimal digit in the largest fitting unit.
+func humanSize(size int64) string {
+	if size < 1024 {
+		return fmt.Sprintf("%db", size)
+	}
+
+	value := float64(size)
+	unit := ""
+
+	for _, unit = range sizeUnits {
+		value /= 1024
+		if value < 1024 {
+			break
+		}
+	}
+
+	return fmt.Sprintf("%.1f%s", value, unit)
+}
+
+// sumSizes sets the size of every directory to the sum of the sizes of its contents,
+// a followed symlink counts as a directory.
+func (n *Node) sumSizes() int64 {
+	if n.Type == TypeFile {
+		return n.Size
+	}
+
+	n.Size = 0
+
+	for _, child := range n.Children {
+		n.Size += child.sumSizes()
+	}
+
+	return n.Size
+}
+
+// prune drops the entries which were read only to count the sizes.
+func (n *Node) prune(lvl int, opts *Options) {
+	if opts.MaxDepth > 0 && lvl > opts.MaxDepth {
+		n.Children = []*Node{}
+		return
+	}
+
+	children := n.Children[:0]
+
+	for _, child := range n.Children {
+		if !child.dir && !opts.PrintFiles {
+			continue
+		}
+
+		child.prune(lvl+1, opts)
+		children = append(children, child)
+	}
+
+	n.Children = children
+}
@@ -0,0 +1,151 @@
+package tree
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+)
+
+// MarshalXML writes the node as <directory>, <file> or <link> element with name and size attributes.
+func (n *Node) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name.Local = n.Type
+	start.Attr = []xml.Attr{
+		{Name: xml.Name{Local: "name"}, Value: n.Name},
+		{Name: xml.Name{Local: "size"}, Value: strconv.FormatInt(n.Size, 10)},
+	}
+
+	optional := []struct{ name, value string }{
+		{"target", n.Target},
+		{"error", n.Err},
+		{"change", n.Change},
+		{"mode", n.Mode},
+		{"owner", n.Owner},
+		{"hash", n.Hash},
+	}
+	if !n.ModTime.IsZero() {
+		optional = append(optional, struct{ name, value string }{"mtime", n.ModTime.Format(time.RFC3339)})
+	}
+
+	for _, attr := range optional {
+		if attr.value != "" {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr.name}, Value: attr.value})
+		}
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, child := range n.Children {
+		if err := e.Encode(child); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON leaves out the zero mtime and the children of the files, but keeps
+// the empty children of the directories, which the omitempty tags alone can't do.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	type plain Node // Without the methods, so it doesn't recurse.
+
+	node := struct {
+		*plain
+		ModTime  *time.Time `json:"mtime,omitempty"`
+		Hash     string     `json:"hash,omitempty"` // Repeated to keep the order of the fields.
+		Children *[]*Node   `json:"children,omitempty"`
+	}{plain: (*plain)(n), Hash: n.Hash}
+
+	if !n.ModTime.IsZero() {
+		node.ModTime = &n.ModTime
+	}
+	if n.Children != nil {
+		node.Children = &n.Children
+	}
+
+	return json.Marshal(node)
+}
+
+// Formats maps the names of the structured output formats to their writers.
+var Formats = map[string]func(out io.Writer, root *Node) error{
+	"json": WriteJSON,
+	"xml":  WriteXML,
+	"html": WriteHTML,
+}
+
+// WriteFormat writes the tree in one of the structured output formats.
+func WriteFormat(out io.Writer, root *Node, format string) error {
+	write, ok := Formats[format]
+	if !ok {
+		return fmt.Errorf("unknown output format %q", format)
+	}
+
+	return write(out, root)
+}
+
+// WriteJSON writes the tree as an indented JSON object, it is also the format of the snapshots.
+func WriteJSON(out io.Writer, root *Node) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(root)
+}
+
+// WriteXML writes the tree as the root <directory> element inside of a <tree> one.
+func WriteXML(out io.Writer, root *Node) error {
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+
+	tree := xml.StartElement{Name: xml.Name{Local: "tree"}}
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+
+	if err := encoder.EncodeToken(tree); err != nil {
+		return err
+	}
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	if err := encoder.EncodeToken(tree.End()); err != nil {
+		return err
+	}
+	if err := encoder.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(out, "\n")
+
+	return err
+}
+
+var htmlTemplate = template.Must(template.New("tree").Funcs(template.FuncMap{"size": func(size int64) string {
+	return formatSize(size, false)
+}}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+</head>
+<body>
+<ul>
+{{template "Node" .}}
+</ul>
+</body>
+</html>
+{{define "Node"}}<li class="{{.Type}}">{{with .Mode}}{{.}} {{end}}{{with .Owner}}{{.}} {{end}}{{if not .ModTime.IsZero}}{{.ModTime.Format "2006-01-02 15:04"}} {{end}}{{with .Hash}}{{.}} {{end}}{{.Name}}{{if .Target}} -&gt; {{.Target}}{{end}}{{if eq .Type "file"}} ({{size .Size}}){{end}}{{if .Err}} [{{.Err}}]{{end}}
+{{- if .Children}}
+<ul>
+{{range .Children}}{{template "Node" .}}
+{{end}}</ul>
+{{- end}}</li>{{end}}`))
+
+// WriteHTML writes the tree as a page with nested lists.
+func WriteHTML(out io.Writer, root *Node) error {
+	return htmlTemplate.Execute(out, root)
+}
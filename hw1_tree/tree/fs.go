@@ -0,0 +1,38 @@
+package tree
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// FileSystem is what the tree is read from, the paths in it are joined with filepath.Join.
+type FileSystem interface {
+	// ReadDir returns the entries of the directory sorted by name without following the symlinks.
+	ReadDir(path string) ([]os.FileInfo, error)
+	// Stat returns the info of the file following the symlinks.
+	Stat(path string) (os.FileInfo, error)
+	Readlink(path string) (string, error)
+	Open(path string) (io.ReadCloser, error)
+}
+
+// OS is the file system of the operating system.
+var OS FileSystem = osFS{}
+
+type osFS struct{}
+
+func (osFS) ReadDir(path string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(path)
+}
+
+func (osFS) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (osFS) Readlink(path string) (string, error) {
+	return os.Readlink(path)
+}
+
+func (osFS) Open(path string) (io.ReadCloser, error) {
+	return os.Open(path)
+}
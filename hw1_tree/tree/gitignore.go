@@ -0,0 +1,136 @@
+package tree
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule is a single pattern line of a .gitignore file.
+type ignoreRule struct {
+	base     string // Directory of the .gitignore file, patterns are relative to it.
+	pattern  string
+	negate   bool // The pattern starts with "!" and re-includes the entry.
+	dirOnly  bool // The pattern ends with "/" and matches directories only.
+	anchored bool // The pattern contains "/" and is matched against the relative path.
+}
+
+// gitIgnore holds the rules of all the .gitignore files from the walk root
+// down to the current directory, the rules of the deeper files go last.
+type gitIgnore []ignoreRule
+
+// load returns the rules extended with the .gitignore file of the dir if there is one.
+// The result never shares the memory with the receiver so the siblings don't see each other rules.
+func (g gitIgnore) load(fs FileSystem, dir string) (gitIgnore, error) {
+	file, err := fs.Open(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules := append(gitIgnore{}, g...)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: dir}
+
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // Escaped leading "#" or "!".
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+
+		if line == "" {
+			continue
+		}
+
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// ignored reports whether the entry is excluded, the last matching rule wins.
+func (g gitIgnore) ignored(name string, isDir bool) bool {
+	result := false
+
+	for _, rule := range g {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		rel, err := filepath.Rel(rule.base, name)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		var ok bool
+		if rule.anchored {
+			ok = matchPath(strings.Split(rule.pattern, "/"), strings.Split(rel, "/"))
+		} else {
+			ok, _ = path.Match(rule.pattern, path.Base(rel))
+		}
+
+		if ok {
+			result = !rule.negate
+		}
+	}
+
+	return result
+}
+
+// matchPath matches the path segments against the pattern segments,
+// where "**" stands for any number of segments.
+func matchPath(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(name); skip++ {
+				if matchPath(pattern[1:], name[skip:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
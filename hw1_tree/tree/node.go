@@ -0,0 +1,57 @@
+// Package tree reads a directory tree into a node model and draws it
+// through a Renderer or writes it in a structured format.
+package tree
+
+import (
+	"os"
+	"time"
+)
+
+// Types of the nodes.
+const (
+	TypeDir  = "directory"
+	TypeFile = "file"
+	TypeLink = "link"
+)
+
+// Node is a single entry of the tree, the root one stands for the walked path itself.
+type Node struct {
+	Name     string    `json:"name"`
+	Type     string    `json:"type"`
+	Size     int64     `json:"size"`
+	Target   string    `json:"target,omitempty"` // Where the symlink points to.
+	Err      string    `json:"error,omitempty"`  // Why the entry can't be read.
+	Change   string    `json:"change,omitempty"` // How the entry differs from the old tree: added, removed or changed.
+	OldSize  int64     `json:"old_size,omitempty"`
+	Mode     string    `json:"mode,omitempty"`
+	Owner    string    `json:"owner,omitempty"`
+	ModTime  time.Time `json:"mtime,omitempty"`    // Left out when zero by MarshalJSON.
+	Hash     string    `json:"hash,omitempty"`     // SHA-256 of the file contents.
+	Children []*Node   `json:"children,omitempty"` // Empty but not nil for the directories, which MarshalJSON keeps.
+
+	dir bool // A directory or a symlink to one.
+}
+
+// newNode makes the node of the entry, the size of a directory is left zero
+// since the one of the directory file itself depends on the file system.
+// So is the one of a symlink unless it is followed.
+func newNode(file entry) *Node {
+	n := &Node{Name: file.Name(), Type: TypeFile, Size: file.Size(), dir: file.isDir()}
+
+	switch {
+	case file.IsDir():
+		n.Type = TypeDir
+		n.Size = 0
+		n.Children = []*Node{}
+	case file.Mode()&os.ModeSymlink != 0:
+		n.Type = TypeLink
+		n.Size = 0
+		n.Target = file.link
+	}
+
+	if file.err != nil {
+		n.Err = errorText(file.err)
+	}
+
+	return n
+}
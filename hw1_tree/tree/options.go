@@ -0,0 +1,59 @@
+package tree
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Options control which entries of the tree are listed and what is known about them.
+type Options struct {
+	PrintFiles  bool
+	MaxDepth    int // Zero means no limit.
+	Include     GlobList
+	Exclude     GlobList
+	GitIgnore   bool
+	DiskUsage   bool   // Report the directories sizes as the sum of their contents.
+	Human       bool   // Print the sizes in K, M and G units.
+	Sort        string // Order of the siblings: name, size, mtime or ext.
+	FollowLinks bool   // Walk into the symlinks to directories.
+	Workers     int    // Number of the directories read in parallel, the walk is sequential below 2.
+	DirsFirst   bool   // List the directories before the files.
+	Perms       bool   // Print the permissions column.
+	Owner       bool   // Print the owner column.
+	ModTime     bool   // Print the modification time column.
+	Hash        bool   // Print the SHA-256 of the files contents.
+	// Where the tree is read from, unless it is set the path
+	// is read from the OS or from the archive if it is one.
+	FS FileSystem
+}
+
+// GlobList is a repeatable flag with file name patterns,
+// a single value may also hold several patterns separated by "|".
+type GlobList []string
+
+func (g *GlobList) String() string {
+	return strings.Join(*g, "|")
+}
+
+func (g *GlobList) Set(value string) error {
+	for _, pattern := range strings.Split(value, "|") {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern %q: %v", pattern, err)
+		}
+		*g = append(*g, pattern)
+	}
+
+	return nil
+}
+
+// match reports whether the name matches any of the patterns.
+func (g GlobList) match(name string) bool {
+	for _, pattern := range g {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package tree
+
+// fileOwner is not supported, the files don't have a numeric owner id on this system.
+func fileOwner(file entry) (string, bool) {
+	return "", false
+}
+
+func lookupUser(uid string) string {
+	return uid
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package tree
+
+import (
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner returns the user id of the file owner.
+func fileOwner(file entry) (string, bool) {
+	stat, ok := file.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", false
+	}
+
+	return strconv.FormatUint(uint64(stat.Uid), 10), true
+}
+
+func lookupUser(uid string) string {
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username
+	}
+
+	return uid
+}
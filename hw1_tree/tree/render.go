@@ -0,0 +1,155 @@
+package tree
+
+import (
+	"io"
+	"strconv"
+)
+
+// Renderer draws the tree node by node in the walk order, so it is
+// able to draw both the built tree and the one being walked.
+type Renderer interface {
+	// RenderNode draws the node, lasts tells for the node and each of its parents
+	// whether it is the last one among its siblings.
+	RenderNode(out io.Writer, n *Node, lasts []bool) error
+}
+
+// Style is the graphics drawn in front of the names.
+type Style struct {
+	Branch     string // In front of a node followed by its siblings.
+	LastBranch string // In front of the last node among its siblings.
+	Pipe       string // Below a parent followed by its siblings.
+	Blank      string // Below the last parent.
+}
+
+var (
+	UnicodeStyle = Style{Branch: "├───", LastBranch: "└───", Pipe: "│\t", Blank: "\t"}
+	ASCIIStyle   = Style{Branch: "|-- ", LastBranch: "`-- ", Pipe: "|   ", Blank: "    "}
+	IndentStyle  = Style{Pipe: "\t", Blank: "\t"}
+)
+
+// LineRenderer draws every node on its own line, what is printed besides the name
+// depends on the same options the tree was walked with.
+type LineRenderer struct {
+	Style   Style
+	Options Options
+}
+
+func NewUnicodeRenderer(opts Options) *LineRenderer {
+	return &LineRenderer{Style: UnicodeStyle, Options: opts}
+}
+
+func NewASCIIRenderer(opts Options) *LineRenderer {
+	return &LineRenderer{Style: ASCIIStyle, Options: opts}
+}
+
+func NewIndentRenderer(opts Options) *LineRenderer {
+	return &LineRenderer{Style: IndentStyle, Options: opts}
+}
+
+func (r *LineRenderer) RenderNode(out io.Writer, n *Node, lasts []bool) error {
+	line := ""
+
+	for _, last := range lasts[:len(lasts)-1] {
+		if last {
+			line += r.Style.Blank
+		} else {
+			line += r.Style.Pipe
+		}
+	}
+
+	if lasts[len(lasts)-1] {
+		line += r.Style.LastBranch
+	} else {
+		line += r.Style.Branch
+	}
+
+	_, err := io.WriteString(out, line+Label(n, &r.Options)+"\n")
+
+	return err
+}
+
+// Label returns the text describing the node: its metadata columns,
+// the name, the target of a symlink, the size and the error.
+func Label(n *Node, opts *Options) string {
+	label := ""
+
+	if cols := columns(n, opts); cols != "" {
+		label += "[" + cols + "] "
+	}
+
+	label += changeMarks[n.Change] + n.Name
+
+	if n.Type == TypeLink {
+		label += " -> " + n.Target
+	}
+
+	if n.Type == TypeFile || opts.DiskUsage {
+		size := formatSize(n.Size, opts.Human)
+
+		if n.Change == ChangeChanged && n.OldSize != n.Size {
+			size = formatSize(n.OldSize, opts.Human) + " -> " + size
+		}
+
+		label += " (" + size + ")"
+	}
+
+	if n.Err != "" {
+		label += " [" + n.Err + "]"
+	}
+
+	return label
+}
+
+func formatSize(size int64, human bool) string {
+	if size <= 0 {
+		return "empty"
+	}
+
+	if human {
+		return humanSize(size)
+	}
+
+	return strconv.FormatInt(size, 10) + "b"
+}
+
+// Render draws the built tree, the root itself is not drawn.
+func Render(out io.Writer, root *Node, r Renderer) error {
+	lasts := make([]bool, 0)
+
+	var render func(n *Node) error
+	render = func(n *Node) error {
+		for idx, child := range n.Children {
+			lasts = append(lasts, idx == len(n.Children)-1)
+
+			if err := r.RenderNode(out, child, lasts); err != nil {
+				return err
+			}
+			if err := render(child); err != nil {
+				return err
+			}
+
+			lasts = lasts[:len(lasts)-1]
+		}
+
+		return nil
+	}
+
+	return render(root)
+}
+
+// Print draws the tree of the path. Unless the sizes of the directories
+// or a custom order are needed, every node is drawn right after it is read.
+func Print(out io.Writer, path string, opts Options, r Renderer) error {
+	if !opts.DiskUsage && (opts.Sort == "" || opts.Sort == "name") && !opts.DirsFirst {
+		return Walk(path, opts, func(n *Node, lasts []bool) error {
+			return r.RenderNode(out, n, lasts)
+		})
+	}
+
+	root, err := Build(path, opts)
+	if err != nil {
+		return err
+	}
+
+	return Render(out, root, r)
+}
@@ -0,0 +1,56 @@
+package tree
+
+import (
+	"path/filepath"
+	"sort"
+)
+
+// Sorts maps the names of the sort types to the functions which tell
+// whether the first node goes before the second one.
+var Sorts = map[string]func(a, b *Node) bool{
+	"name": func(a, b *Node) bool {
+		return a.Name < b.Name
+	},
+	// The largest go first to quickly find out what takes the space.
+	"size": func(a, b *Node) bool {
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Name < b.Name
+	},
+	// The newest go first as in "ls -t".
+	"mtime": func(a, b *Node) bool {
+		if !a.ModTime.Equal(b.ModTime) {
+			return a.ModTime.After(b.ModTime)
+		}
+		return a.Name < b.Name
+	},
+	"ext": func(a, b *Node) bool {
+		extA, extB := filepath.Ext(a.Name), filepath.Ext(b.Name)
+		if extA != extB {
+			return extA < extB
+		}
+		return a.Name < b.Name
+	},
+}
+
+// dirsFirst puts the directories before the files keeping the order inside of both groups.
+func dirsFirst(less func(a, b *Node) bool) func(a, b *Node) bool {
+	return func(a, b *Node) bool {
+		if a.dir != b.dir {
+			return a.dir
+		}
+		return less(a, b)
+	}
+}
+
+// sort orders the siblings on every level of the tree.
+func (n *Node) sort(less func(a, b *Node) bool) {
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		return less(n.Children[i], n.Children[j])
+	})
+
+	for _, child := range n.Children {
+		child.sort(less)
+	}
+}
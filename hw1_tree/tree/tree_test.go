@@ -0,0 +1,538 @@
+package tree
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func printTree(out io.Writer, path string, opts Options) error {
+	return Print(out, path, opts, NewUnicodeRenderer(opts))
+}
+
+func TestTreeOptions(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			name: "depth",
+			opts: Options{PrintFiles: true, MaxDepth: 1},
+			expected: `├───project
+├───static
+├───zline
+└───zzfile.txt (empty)
+`,
+		},
+		{
+			name: "exclude",
+			opts: Options{PrintFiles: true, MaxDepth: 2, Exclude: GlobList{"static", "*.png"}},
+			expected: `├───project
+│	└───file.txt (19b)
+├───zline
+│	├───empty.txt (empty)
+│	└───lorem
+└───zzfile.txt (empty)
+`,
+		},
+		{
+			name: "include",
+			opts: Options{PrintFiles: true, Include: GlobList{"*.css", "*.js"}, Exclude: GlobList{"*lorem"}},
+			expected: `├───project
+├───static
+│	├───css
+│	│	└───body.css (28b)
+│	├───html
+│	└───js
+│		└───site.js (10b)
+└───zline
+`,
+		},
+	}
+
+	for _, item := range cases {
+		out := new(bytes.Buffer)
+		err := printTree(out, "../testdata", item.opts)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", item.name, err)
+		}
+		result := out.String()
+		if result != item.expected {
+			t.Errorf("[%s] results not match\nGot:\n%v\nExpected:\n%v", item.name, result, item.expected)
+		}
+	}
+}
+
+func TestTreeGitIgnore(t *testing.T) {
+	root, err := ioutil.TempDir("", "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		".gitignore":          "*.log\nbuild/\n/vendor\n!keep.log\n",
+		"main.go":             "",
+		"debug.log":           "",
+		"keep.log":            "",
+		"build/out.bin":       "",
+		"vendor/lib.go":       "",
+		"pkg/.gitignore":      "gen/**/*.go\n",
+		"pkg/vendor/lib.go":   "",
+		"pkg/gen/a/b.go":      "",
+		"pkg/gen/a/trace.log": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := `├───.gitignore (31b)
+├───keep.log (empty)
+├───main.go (empty)
+└───pkg
+	├───.gitignore (12b)
+	├───gen
+	│	└───a
+	└───vendor
+		└───lib.go (empty)
+`
+
+	out := new(bytes.Buffer)
+	err = printTree(out, root, Options{PrintFiles: true, GitIgnore: true})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	result := out.String()
+	if result != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+const testDiskUsageResult = `├───zline (137.4K)
+│	├───lorem (137.4K)
+│	└───empty.txt (empty)
+├───project (68.7K)
+│	├───gopher.png (68.7K)
+│	└───file.txt (19b)
+└───zzfile.txt (empty)
+`
+
+// countingFS counts the directories and the files read by the walk.
+type countingFS struct {
+	FileSystem
+	reads, opens int32
+}
+
+func (c *countingFS) ReadDir(path string) ([]os.FileInfo, error) {
+	atomic.AddInt32(&c.reads, 1)
+	return c.FileSystem.ReadDir(path)
+}
+
+func (c *countingFS) Open(path string) (io.ReadCloser, error) {
+	atomic.AddInt32(&c.opens, 1)
+	return c.FileSystem.Open(path)
+}
+
+func TestWalkReadAhead(t *testing.T) {
+	root, err := ioutil.TempDir("", "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for i := 0; i < 20; i++ {
+		if err := os.MkdirAll(filepath.Join(root, fmt.Sprintf("d%02d", i), "sub"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := &countingFS{FileSystem: OS}
+	opts := Options{FS: fs, Workers: 2}
+	first := true
+
+	err = Walk(root, opts, func(n *Node, lasts []bool) error {
+		if first {
+			// Only a window of the next siblings is read while the first one is visited.
+			first = false
+			time.Sleep(50 * time.Millisecond)
+
+			if reads := atomic.LoadInt32(&fs.reads); reads > 1+2*int32(opts.Workers) {
+				t.Errorf("%d directories are read ahead", reads)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reads := atomic.LoadInt32(&fs.reads); reads != 41 {
+		t.Errorf("results not match\nGot: %d reads\nExpected: 41 reads", reads)
+	}
+}
+
+func TestTreeDiskUsage(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := printTree(out, "../testdata", Options{PrintFiles: true, MaxDepth: 2, Exclude: GlobList{"static"}, DiskUsage: true, Human: true, Sort: "size"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	result := out.String()
+	if result != testDiskUsageResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testDiskUsageResult)
+	}
+
+	// Only the listed files are hashed, though all of them are read to count the sizes.
+	fs := &countingFS{FileSystem: OS}
+	root, err := Build("../testdata", Options{MaxDepth: 1, DiskUsage: true, Hash: true, FS: fs})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if root.Size == 0 || fs.opens != 0 {
+		t.Errorf("results not match\nGot: %d bytes, %d files hashed\nExpected: the size of the testdata, 0 files hashed", root.Size, fs.opens)
+	}
+}
+
+const testSymlinksResult = `├───a
+│	├───file.txt (empty)
+│	└───up -> .. [recursive, not followed]
+├───broken -> missing [broken link]
+└───link -> a
+	├───file.txt (empty)
+	└───up -> .. [recursive, not followed]
+`
+
+func TestTreeSymlinks(t *testing.T) {
+	root, err := ioutil.TempDir("", "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	if err := os.Mkdir(filepath.Join(root, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "a", "file.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{"a/up": "..", "broken": "missing", "link": "a"} {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Skipf("symlinks are not supported: %v", err)
+		}
+	}
+
+	out := new(bytes.Buffer)
+	err = printTree(out, root, Options{PrintFiles: true, FollowLinks: true})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	result := out.String()
+	if result != testSymlinksResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, testSymlinksResult)
+	}
+}
+
+func TestTreePermissionDenied(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any directory")
+	}
+
+	root, err := ioutil.TempDir("", "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, name := range []string{"locked", "open"} {
+		if err := os.Mkdir(filepath.Join(root, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(root, "locked"), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(filepath.Join(root, "locked"), 0755)
+
+	expected := "├───locked [permission denied]\n└───open\n"
+
+	out := new(bytes.Buffer)
+	err = printTree(out, root, Options{})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	result := out.String()
+	if result != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestTreeColumns(t *testing.T) {
+	root, err := ioutil.TempDir("", "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	modTime := time.Date(2018, 3, 1, 12, 30, 0, 0, time.Local)
+	files := []struct {
+		name    string
+		content string
+		age     time.Duration
+	}{
+		{"b.txt", "hello", 2 * time.Hour},
+		{"a.go", "", time.Hour},
+		{"c.go", "", 3 * time.Hour},
+	}
+	for _, file := range files {
+		path := filepath.Join(root, file.name)
+		if err := ioutil.WriteFile(path, []byte(file.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, 0640); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime.Add(-file.age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, "z"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			name: "mtime",
+			opts: Options{PrintFiles: true, Sort: "mtime", ModTime: true, Perms: true, Exclude: GlobList{"z"}},
+			expected: `├───[-rw-r----- 2018-03-01 11:30] a.go (empty)
+├───[-rw-r----- 2018-03-01 10:30] b.txt (5b)
+└───[-rw-r----- 2018-03-01 09:30] c.go (empty)
+`,
+		},
+		{
+			name: "ext",
+			opts: Options{PrintFiles: true, Sort: "ext", DirsFirst: true, Hash: true},
+			expected: `├───z
+├───[e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855] a.go (empty)
+├───[e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855] c.go (empty)
+└───[2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824] b.txt (5b)
+`,
+		},
+	}
+
+	for _, item := range cases {
+		out := new(bytes.Buffer)
+		err := printTree(out, root, item.opts)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", item.name, err)
+		}
+		result := out.String()
+		if result != item.expected {
+			t.Errorf("[%s] results not match\nGot:\n%v\nExpected:\n%v", item.name, result, item.expected)
+		}
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	opts := Options{PrintFiles: true}
+	cases := []struct {
+		name     string
+		renderer Renderer
+		expected string
+	}{
+		{
+			name:     "ascii",
+			renderer: NewASCIIRenderer(opts),
+			expected: "|-- empty.txt (empty)\n" +
+				"`-- lorem\n" +
+				"    |-- dolor.txt (empty)\n" +
+				"    |-- gopher.png (70372b)\n" +
+				"    `-- ipsum\n" +
+				"        `-- gopher.png (70372b)\n",
+		},
+		{
+			name:     "indent",
+			renderer: NewIndentRenderer(opts),
+			expected: "empty.txt (empty)\n" +
+				"lorem\n" +
+				"\tdolor.txt (empty)\n" +
+				"\tgopher.png (70372b)\n" +
+				"\tipsum\n" +
+				"\t\tgopher.png (70372b)\n",
+		},
+	}
+
+	root, err := Build("../testdata/zline", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, item := range cases {
+		// The built tree and the walked one have to be drawn the same way.
+		rendered, printed := new(bytes.Buffer), new(bytes.Buffer)
+
+		if err := Render(rendered, root, item.renderer); err != nil {
+			t.Errorf("[%s] unexpected error: %v", item.name, err)
+		}
+		if err := Print(printed, "../testdata/zline", opts, item.renderer); err != nil {
+			t.Errorf("[%s] unexpected error: %v", item.name, err)
+		}
+
+		for _, result := range []string{rendered.String(), printed.String()} {
+			if result != item.expected {
+				t.Errorf("[%s] results not match\nGot:\n%v\nExpected:\n%v", item.name, result, item.expected)
+			}
+		}
+	}
+}
+
+func TestArchives(t *testing.T) {
+	root, err := ioutil.TempDir("", "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// The "dir" itself is not stored in the archives on purpose.
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"dir/a.txt", ""},
+		{"b.txt", "hello"},
+		{"big.txt", strings.Repeat("0123456789abcdef", tarCacheFile/8)}, // Too big to be kept by the index pass.
+		{"dir/sub/", ""},
+	}
+
+	zipPath := filepath.Join(root, "test.zip")
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipWriter := zip.NewWriter(zipFile)
+	for _, file := range files {
+		writer, err := zipWriter.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(writer, file.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zipFile.Close()
+
+	tarPath := filepath.Join(root, "test.tar.gz")
+	tarFile, err := os.Create(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzWriter := gzip.NewWriter(tarFile)
+	tarWriter := tar.NewWriter(gzWriter)
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(file.name, "/") {
+			header.Mode, header.Typeflag = 0755, tar.TypeDir
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(tarWriter, file.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gzWriter.Close()
+	tarFile.Close()
+
+	expected := `├───b.txt (5b)
+├───big.txt (2097152b)
+└───dir
+	├───a.txt (empty)
+	└───sub
+`
+
+	for _, path := range []string{zipPath, tarPath} {
+		out := new(bytes.Buffer)
+		err := printTree(out, path, Options{PrintFiles: true})
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", path, err)
+		}
+		result := out.String()
+		if result != expected {
+			t.Errorf("[%s] results not match\nGot:\n%v\nExpected:\n%v", path, result, expected)
+		}
+	}
+
+	// The files of a tar are hashed by the index pass, not by scanning it again on open.
+	big := files[2].content
+	hashed, err := openArchive(tarPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed.Close()
+
+	hash, _ := hashed.hash(filepath.Join(tarPath, "big.txt"))
+	if expected := fmt.Sprintf("%x", sha256.Sum256([]byte(big))); hash != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", hash, expected)
+	}
+
+	archive, err := OpenArchive(tarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.Close()
+
+	// The big file is streamed from the archive.
+	contents, err := archive.Open(filepath.Join(tarPath, "big.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, _ := ioutil.ReadAll(contents)
+	contents.Close()
+
+	if string(data) != big {
+		t.Errorf("results not match\nGot: %d bytes\nExpected: %d bytes", len(data), len(big))
+	}
+
+	// The small files of a tar are read by the index pass, not by scanning it again on open.
+	if err := os.Remove(tarPath); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err = archive.Open(filepath.Join(tarPath, "b.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer contents.Close()
+
+	if data, _ := ioutil.ReadAll(contents); string(data) != "hello" {
+		t.Errorf("results not match\nGot: %q\nExpected: %q", data, "hello")
+	}
+}
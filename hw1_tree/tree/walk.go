@@ -0,0 +1,348 @@
+package tree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+var (
+	errBrokenLink = errors.New("broken link")
+	errRecursive  = errors.New("recursive, not followed")
+)
+
+// entry is an item of a directory, the target of a symlink is resolved on read.
+type entry struct {
+	os.FileInfo
+	link   string      // Target of the symlink as it is written in the link.
+	target os.FileInfo // Nil unless the entry is a valid symlink.
+	err    error       // Why the symlink can't be resolved.
+}
+
+// isDir reports whether the entry is a directory or a symlink to one.
+func (e entry) isDir() bool {
+	return e.IsDir() || (e.target != nil && e.target.IsDir())
+}
+
+// readDir reads the directory sorted by name and resolves all the symlinks in it.
+func (w *walker) readDir(path string) ([]entry, error) {
+	infos, err := w.fs.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := make([]entry, len(infos))
+
+	for idx, info := range infos {
+		dir[idx].FileInfo = info
+
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		linkPath := filepath.Join(path, info.Name())
+
+		if dir[idx].link, err = w.fs.Readlink(linkPath); err != nil {
+			dir[idx].err = err
+			continue
+		}
+
+		if dir[idx].target, err = w.fs.Stat(linkPath); os.IsNotExist(err) {
+			dir[idx].err = errBrokenLink
+		} else if err != nil {
+			dir[idx].err = err
+		}
+	}
+
+	return dir, nil
+}
+
+// Walk passes every node of the tree of the path to the visit callback as soon as it is read,
+// lasts tells for the node and each of its parents whether it is the last one among its siblings.
+// Only the errors of the root are returned while the ones of the nested entries are set on the nodes.
+func Walk(path string, opts Options, visit func(n *Node, lasts []bool) error) error {
+	w := newWalker(opts)
+	w.visit = visit
+
+	return w.walk(path, nil)
+}
+
+// Build reads the whole tree into memory, the root node stands for the path itself.
+func Build(path string, opts Options) (*Node, error) {
+	root := &Node{Name: path, Type: TypeDir, Children: []*Node{}, dir: true}
+	w := newWalker(opts)
+	w.build = true
+	w.countAll = opts.DiskUsage
+
+	if err := w.walk(path, root); err != nil {
+		return nil, err
+	}
+
+	if opts.DiskUsage {
+		root.sumSizes()
+		root.prune(1, &opts)
+	}
+
+	if opts.Sort != "" {
+		less := Sorts[opts.Sort]
+		if opts.DirsFirst {
+			less = dirsFirst(less)
+		}
+
+		root.sort(less)
+	}
+
+	return root, nil
+}
+
+type walker struct {
+	opts  Options
+	build bool // Keep the read entries as the children of their parents.
+	// Read all the files and subdirectories regardless of -f and -L to count the sizes.
+	countAll bool
+	lasts    []bool
+	// The directories from the root down to the current one, a symlink
+	// to any of them would make a loop so it is never followed.
+	ancestors []os.FileInfo
+
+	fs     FileSystem
+	owners map[string]string // Names of the users by their ids.
+
+	// Limits the number of the directories read at once, nil for the sequential walk.
+	readers chan struct{}
+
+	// visit is called for every entry right after it is read,
+	// lasts tells for the entry and each of its parents whether it is the last one on its level.
+	visit func(n *Node, lasts []bool) error
+}
+
+func newWalker(opts Options) *walker {
+	w := &walker{opts: opts, fs: opts.FS}
+
+	if opts.Workers > 1 {
+		w.readers = make(chan struct{}, opts.Workers)
+	}
+
+	return w
+}
+
+// pendingDir is a directory being read in the background.
+type pendingDir struct {
+	done chan struct{}
+	dir  []entry
+	err  error
+}
+
+// readAhead starts reading the directory as soon as there is a free reader.
+func (w *walker) readAhead(path string) *pendingDir {
+	pending := &pendingDir{done: make(chan struct{})}
+
+	go func() {
+		w.readers <- struct{}{}
+		pending.dir, pending.err = w.readDir(path)
+		<-w.readers
+		close(pending.done)
+	}()
+
+	return pending
+}
+
+func (p *pendingDir) wait() ([]entry, error) {
+	<-p.done
+
+	return p.dir, p.err
+}
+
+// walk scans the tree of the path, only the errors of the root are returned
+// while the ones of the nested entries are reported inline.
+func (w *walker) walk(path string, root *Node) error {
+	if w.fs == nil {
+		w.fs = OS
+
+		if IsArchive(path) {
+			archive, err := openArchive(path, w.opts.Hash)
+			if err != nil {
+				return err
+			}
+			defer archive.Close()
+
+			w.fs = archive
+		}
+	}
+
+	info, err := w.fs.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	dir, err := w.readDir(path)
+	if err != nil {
+		return err
+	}
+
+	w.ancestors = []os.FileInfo{info}
+
+	return w.scanDir(path, dir, 1, nil, root)
+}
+
+// scanDir passes every entry of the dir to the visit callback as soon as it is read,
+// so unless the tree is built the memory usage doesn't depend on the size of the tree.
+func (w *walker) scanDir(path string, dir []entry, lvl int, ignore gitIgnore, parent *Node) error {
+	var err error
+
+	if w.opts.GitIgnore {
+		ignore, err = ignore.load(w.fs, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	dir = w.filterFiles(path, dir, ignore)
+
+	// Up to Workers of the next subdirectories are read in parallel while the entries
+	// are still visited one by one in the same order as in the sequential walk.
+	// So no more than Workers listings wait in memory on every level.
+	var pending []*pendingDir
+	ahead, queued := 0, 0 // The next entry to be read ahead and the reads not visited yet.
+
+	if w.readers != nil {
+		pending = make([]*pendingDir, len(dir))
+	}
+
+	for idx, file := range dir {
+		for ; pending != nil && ahead < len(dir) && queued < w.opts.Workers; ahead++ {
+			if w.mayDescend(dir[ahead], lvl) {
+				pending[ahead] = w.readAhead(filepath.Join(path, dir[ahead].Name()))
+				queued++
+			}
+		}
+
+		if pending != nil && pending[idx] != nil {
+			queued--
+		}
+
+		n := newNode(file)
+		w.describe(n, file, filepath.Join(path, file.Name()), lvl)
+		w.lasts = append(w.lasts[:lvl-1], idx == len(dir)-1)
+
+		if w.build {
+			parent.Children = append(parent.Children, n)
+		}
+
+		// The subdirectory is read before the visit to report its error on the same line.
+		subPath := filepath.Join(path, file.Name())
+		subDir := []entry(nil)
+		descend := w.descend(file, lvl, n)
+
+		if descend {
+			if pending != nil {
+				subDir, err = pending[idx].wait()
+			} else {
+				subDir, err = w.readDir(subPath)
+			}
+
+			if err != nil {
+				n.Err = errorText(err)
+				descend = false
+			}
+		}
+
+		if w.visit != nil {
+			if err := w.visit(n, w.lasts); err != nil {
+				return err
+			}
+		}
+
+		if !descend {
+			continue
+		}
+
+		info := file.FileInfo
+		if file.target != nil {
+			info = file.target
+		}
+
+		w.ancestors = append(w.ancestors, info)
+		err = w.scanDir(subPath, subDir, lvl+1, ignore, n)
+		w.ancestors = w.ancestors[:len(w.ancestors)-1]
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mayDescend reports whether the walk would go inside of the entry unless it makes a loop.
+// Directories below the depth limit are never read.
+func (w *walker) mayDescend(file entry, lvl int) bool {
+	if !file.isDir() || (!w.countAll && w.opts.MaxDepth > 0 && lvl >= w.opts.MaxDepth) {
+		return false
+	}
+
+	return file.target == nil || w.opts.FollowLinks
+}
+
+// listed reports whether the entry on the level is in the output,
+// the ones read only to count the sizes aren't.
+func (w *walker) listed(file entry, lvl int) bool {
+	return (file.isDir() || w.opts.PrintFiles) && (w.opts.MaxDepth <= 0 || lvl <= w.opts.MaxDepth)
+}
+
+// descend reports whether the walk goes inside of the entry.
+func (w *walker) descend(file entry, lvl int, n *Node) bool {
+	if !w.mayDescend(file, lvl) {
+		return false
+	}
+
+	if file.target == nil {
+		return true
+	}
+
+	for _, parent := range w.ancestors {
+		if os.SameFile(parent, file.target) {
+			n.Err = errRecursive.Error()
+			return false
+		}
+	}
+
+	return true
+}
+
+// filterFiles drops the entries which must not be listed,
+// it has to be done before the output to know which entry is the last one.
+func (w *walker) filterFiles(path string, dir []entry, ignore gitIgnore) []entry {
+	tmpDir := make([]entry, 0, len(dir))
+
+	for _, file := range dir {
+		if !file.isDir() && !w.opts.PrintFiles && !w.countAll {
+			continue
+		}
+
+		if w.opts.Exclude.match(file.Name()) {
+			continue
+		}
+
+		if !file.isDir() && len(w.opts.Include) > 0 && !w.opts.Include.match(file.Name()) {
+			continue
+		}
+
+		if ignore.ignored(filepath.Join(path, file.Name()), file.isDir()) {
+			continue
+		}
+
+		tmpDir = append(tmpDir, file)
+	}
+
+	return tmpDir
+}
+
+// errorText strips the path from the error since the entry is already named in the output.
+func errorText(err error) string {
+	if pathErr, ok := err.(*os.PathError); ok {
+		return pathErr.Err.Error()
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Cache memoizes up to size of the recently used signatures of the signer.
+// The concurrent calls for the same data wait for the first one instead of signing it again.
+type Cache struct {
+	signer Signer
+	size   int
+
+	mu           sync.Mutex
+	items        map[cacheKey]*list.Element // Values are cacheItem, the front one is the most recent.
+	recent       *list.List
+	calls        map[cacheKey]*cacheCall // Signatures being calculated.
+	hits, misses uint64
+}
+
+// cacheKey is the data with DataSignerSalt, the signature changes with the salt.
+type cacheKey struct {
+	data, salt string
+}
+
+type cacheItem struct {
+	key       cacheKey
+	signature string
+}
+
+type cacheCall struct {
+	done      chan struct{}
+	signature string
+	ok        bool // False if the signer panicked or gave up.
+}
+
+// NewCache puts the cache in front of the signer, zero or less size means no limit.
+func NewCache(signer Signer, size int) *Cache {
+	return &Cache{
+		signer: signer,
+		size:   size,
+		items:  make(map[cacheKey]*list.Element),
+		recent: list.New(),
+		calls:  make(map[cacheKey]*cacheCall),
+	}
+}
+
+func (c *Cache) Sign(data string) string {
+	signature, _ := c.SignContext(context.Background(), data)
+	return signature
+}
+
+// SignContext stops waiting for the signature when the context is done,
+// the signer is given the context too if it's a ContextSigner.
+func (c *Cache) SignContext(ctx context.Context, data string) (string, error) {
+	key := cacheKey{data: data, salt: DataSignerSalt}
+
+	c.mu.Lock()
+
+	if elem, ok := c.items[key]; ok {
+		c.hits++
+		c.recent.MoveToFront(elem)
+		c.mu.Unlock()
+
+		return elem.Value.(cacheItem).signature, nil
+	}
+
+	if call, ok := c.calls[key]; ok {
+		c.hits++
+		c.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+
+		if !call.ok {
+			// The first call panicked or gave up, this one tries again on its own.
+			return c.SignContext(ctx, data)
+		}
+
+		return call.signature, nil
+	}
+
+	c.misses++
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	// The waiters are let go even if the signer panics.
+	defer func() {
+		c.mu.Lock()
+		delete(c.calls, key)
+
+		if call.ok {
+			c.items[key] = c.recent.PushFront(cacheItem{key: key, signature: call.signature})
+
+			if c.size > 0 && c.recent.Len() > c.size {
+				oldest := c.recent.Back()
+				c.recent.Remove(oldest)
+				delete(c.items, oldest.Value.(cacheItem).key)
+			}
+		}
+		c.mu.Unlock()
+
+		close(call.done)
+	}()
+
+	signature, err := signContext(ctx, c.signer, data)
+	if err != nil {
+		return "", err
+	}
+
+	call.signature, call.ok = signature, true
+
+	return signature, nil
+}
+
+// Stats returns the number of the calls served by the cache and the ones passed to the signer.
+func (c *Cache) Stats() (hits, misses uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+// Cached makes a copy of the scheme with the signers behind the caches of the size,
+// e.g. DefaultScheme = DefaultScheme.Cached(1000) to cache the hashes of ExecutePipeline.
+// The same signer used in several places shares one cache, the signers which can't be
+// compared get a cache of their own.
+func (s *Scheme) Cached(size int) *Scheme {
+	caches := make(map[Signer]*Cache)
+	cache := func(signer Signer) Signer {
+		if !reflect.ValueOf(signer).Comparable() {
+			return NewCache(signer, size)
+		}
+
+		if _, ok := caches[signer]; !ok {
+			caches[signer] = NewCache(signer, size)
+		}
+
+		return caches[signer]
+	}
+
+	cached := &Scheme{
+		Single: make([][]Signer, 0, len(s.Single)),
+		Multi:  cache(s.Multi),
+		Rounds: s.Rounds,
+	}
+
+	for _, chain := range s.Single {
+		cachedChain := make([]Signer, 0, len(chain))
+		for _, signer := range chain {
+			cachedChain = append(cachedChain, cache(signer))
+		}
+
+		cached.Single = append(cached.Single, cachedChain)
+	}
+
+	return cached
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sync"
+)
+
+// FanOut runs n copies of the job, every input value goes to one of them
+// and their outputs are merged in the order the values are ready. It panics if n is less than 1.
+func FanOut(n int, job0 job) job {
+	if n < 1 {
+		panic("FanOut: n must be at least 1")
+	}
+
+	return func(in, out chan interface{}) {
+		outs := make([]chan interface{}, 0, n)
+
+		for i := 0; i < n; i++ {
+			outs = append(outs, runJob(job0, in))
+		}
+
+		mergeInto(out, outs...)
+	}
+}
+
+// Merge passes every input value to all of the jobs and merges their outputs,
+// e.g. the jobs which don't read the input are the streams to merge.
+func Merge(jobs ...job) job {
+	return func(in, out chan interface{}) {
+		ins := make([]chan interface{}, 0, len(jobs))
+		outs := make([]chan interface{}, 0, len(jobs))
+
+		for _, job0 := range jobs {
+			jobIn := make(chan interface{})
+			ins = append(ins, jobIn)
+			outs = append(outs, runJob(job0, jobIn))
+		}
+
+		go broadcast(in, ins...)
+
+		mergeInto(out, outs...)
+	}
+}
+
+// Split passes the input values matching the predicate to the first job
+// and the rest of them to the second one, their outputs are merged.
+func Split(match func(interface{}) bool, matched, rest job) job {
+	return func(in, out chan interface{}) {
+		matchedIn := make(chan interface{})
+		restIn := make(chan interface{})
+
+		go func() {
+			defer close(matchedIn)
+			defer close(restIn)
+
+			for value := range in {
+				if match(value) {
+					matchedIn <- value
+				} else {
+					restIn <- value
+				}
+			}
+		}()
+
+		mergeInto(out, runJob(matched, matchedIn), runJob(rest, restIn))
+	}
+}
+
+// Tee passes the input values further as they are and copies them to the side job,
+// e.g. to log or count them. The output of the side job is merged too.
+func Tee(side job) job {
+	return Merge(func(in, out chan interface{}) {
+		for value := range in {
+			out <- value
+		}
+	}, side)
+}
+
+// runJob runs the job in its own goroutine, its output is closed when the job returns.
+// The rest of the input is drained then, so the ones sending to it aren't blocked
+// by the job which stops reading early.
+func runJob(job0 job, in chan interface{}) chan interface{} {
+	out := make(chan interface{})
+
+	go func() {
+		defer close(out)
+		job0(in, out)
+
+		for range in {
+		}
+	}()
+
+	return out
+}
+
+// broadcast copies every value of the input to all the outputs, which are closed at the end.
+func broadcast(in chan interface{}, outs ...chan interface{}) {
+	for value := range in {
+		for _, out := range outs {
+			out <- value
+		}
+	}
+
+	for _, out := range outs {
+		close(out)
+	}
+}
+
+// mergeInto passes the values of all the inputs to the output until all of them are closed.
+func mergeInto(out chan interface{}, ins ...chan interface{}) {
+	wg := &sync.WaitGroup{}
+
+	for _, in := range ins {
+		wg.Add(1)
+
+		go func(in chan interface{}) {
+			defer wg.Done()
+
+			for value := range in {
+				out <- value
+			}
+		}(in)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCombinators(t *testing.T) {
+	numbers := func(from, to int) job {
+		return func(in, out chan interface{}) {
+			for i := from; i < to; i++ {
+				out <- i
+			}
+		}
+	}
+	double := func(in, out chan interface{}) {
+		for value := range in {
+			out <- value.(int) * 2
+		}
+	}
+	negate := func(in, out chan interface{}) {
+		for value := range in {
+			out <- -value.(int)
+		}
+	}
+
+	var seen int32
+	count := func(in, out chan interface{}) {
+		for range in {
+			atomic.AddInt32(&seen, 1)
+		}
+	}
+
+	var results []int
+	collect := func(in, out chan interface{}) {
+		for value := range in {
+			results = append(results, value.(int))
+		}
+	}
+
+	isEven := func(value interface{}) bool {
+		return value.(int)%2 == 0
+	}
+
+	// 0..4 and 5..9 are merged, counted, the even ones are doubled by 3 workers
+	// and the odd ones are negated.
+	ExecutePipeline(
+		Merge(numbers(0, 5), numbers(5, 10)),
+		Tee(count),
+		Split(isEven, FanOut(3, double), negate),
+		collect,
+	)
+
+	sort.Ints(results)
+	expected := []int{-9, -7, -5, -3, -1, 0, 4, 8, 12, 16}
+
+	if len(results) != len(expected) || seen != 10 {
+		t.Fatalf("results not match\nGot: %v, %d seen\nExpected: %v, 10 seen", results, seen, expected)
+	}
+
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Fatalf("results not match\nGot: %v\nExpected: %v", results, expected)
+		}
+	}
+}
+
+func TestCombinatorsEarlyReturn(t *testing.T) {
+	numbers := func(in, out chan interface{}) {
+		for i := 0; i < 10; i++ {
+			out <- i
+		}
+	}
+	// first takes only the first value and returns.
+	first := func(in, out chan interface{}) {
+		<-in
+	}
+	// extra ignores the input and sends a value of its own.
+	extra := func(in, out chan interface{}) {
+		out <- 100
+	}
+
+	cases := []struct {
+		name     string
+		job      job
+		expected int // Number of the results.
+	}{
+		{"tee", Tee(first), 10},
+		{"merge", Merge(extra), 1},
+	}
+
+	for _, c := range cases {
+		results := 0
+		collect := func(in, out chan interface{}) {
+			for range in {
+				results++
+			}
+		}
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			ExecutePipeline(numbers, c.job, collect)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: the pipeline is blocked by the job which doesn't read its input", c.name)
+		}
+
+		if results != c.expected {
+			t.Errorf("%s: results not match\nGot: %d\nExpected: %d", c.name, results, c.expected)
+		}
+	}
+}
+
+func TestFanOutZero(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("no panic for zero copies of the job")
+		}
+	}()
+
+	FanOut(0, func(in, out chan interface{}) {})
+}
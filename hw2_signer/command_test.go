@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	opts := options{workers: 2, single: "sha1~xxhash(sha256)", multi: "crc32c", rounds: 2}
+	scheme, err := ParseScheme(opts.single, opts.multi, opts.rounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &strings.Builder{}
+	if err := run(context.Background(), out, strings.NewReader("1\n\n007\nfoo\n"), nil, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	multis := []string{}
+	for _, data := range []string{"1", "7", "foo"} {
+		multis = append(multis, scheme.MultiHash(scheme.SingleHashString(data)))
+	}
+	sort.Strings(multis)
+
+	if expected := strings.Join(multis, "_") + "\n"; out.String() != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", out, expected)
+	}
+
+	opts.perItem = true
+	opts.salt = "pepper"
+	defer func() { DataSignerSalt = "" }()
+
+	out.Reset()
+	if err := run(context.Background(), out, strings.NewReader("1\n007\n"), nil, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	DataSignerSalt = "pepper"
+	single1, single7 := scheme.SingleHashString("1"), scheme.SingleHashString("7")
+	multi1, multi7 := scheme.MultiHash(single1), scheme.MultiHash(single7)
+	multis = []string{multi1, multi7}
+	sort.Strings(multis)
+
+	expected := "1\t" + single1 + "\t" + multi1 + "\n" +
+		"007\t" + single7 + "\t" + multi7 + "\n" +
+		strings.Join(multis, "_") + "\n"
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out, expected)
+	}
+
+	if err := run(context.Background(), out, nil, []string{"no-such-file"}, opts); err == nil {
+		t.Errorf("no error for a missing file")
+	}
+}
@@ -1,11 +1,11 @@
 package main
 
 import (
+	"context"
 	"crypto/md5"
 	"fmt"
 	"hash/crc32"
 	"strconv"
-	"sync/atomic"
 	"time"
 )
 
@@ -20,26 +20,21 @@ var (
 	DataSignerSalt            = ""
 )
 
-var OverheatLock = func() {
-	for {
-		if swapped := atomic.CompareAndSwapUint32(&dataSignerOverheat, 0, 1); !swapped {
-			fmt.Println("OverheatLock happend")
-			time.Sleep(time.Second)
-		} else {
-			break
-		}
-	}
+// Md5Limiter lets DataSignerMd5 be called only once at a time, the concurrent calls
+// wait for their turn instead of spinning. OverheatLock has no context, so they can't
+// give up waiting here, the pipelines wait for their turn in Md5Signer instead.
+var Md5Limiter = NewLimiter(1)
+
+var OverheatLock = lockMd5
+
+var OverheatUnlock = unlockMd5
+
+func lockMd5() {
+	_ = Md5Limiter.Acquire(context.Background())
 }
 
-var OverheatUnlock = func() {
-	for {
-		if swapped := atomic.CompareAndSwapUint32(&dataSignerOverheat, 1, 0); !swapped {
-			fmt.Println("OverheatUnlock happend")
-			time.Sleep(time.Second)
-		} else {
-			break
-		}
-	}
+func unlockMd5() {
+	Md5Limiter.Release()
 }
 
 var DataSignerMd5 = func(data string) string {
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter lets up to limit holders in at once, the waiting ones are let in
+// in the order they came.
+type Limiter struct {
+	mu      sync.Mutex
+	limit   int
+	held    int
+	waiters *list.List // Values are chan struct{}, closed when the waiter is let in.
+	stats   LimiterStats
+}
+
+// LimiterStats are the counters of the limiter.
+type LimiterStats struct {
+	Acquired  uint64        // Acquisitions, both the immediate and the waited ones.
+	Contended uint64        // Acquisitions which had to wait.
+	Canceled  uint64        // Acquisitions given up because the context was done.
+	WaitTime  time.Duration // Sum of the time spent waiting.
+	Held      int           // Holders right now.
+	Waiting   int           // Waiters right now.
+}
+
+// NewLimiter makes the limiter of the limit holders, it's at least 1.
+func NewLimiter(limit int) *Limiter {
+	l := &Limiter{waiters: list.New()}
+	l.SetLimit(limit)
+
+	return l
+}
+
+// SetLimit changes the limit, the waiters are let in at once if it's raised,
+// the holders above it aren't pushed out if it's lowered.
+func (l *Limiter) SetLimit(limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = limit
+	l.letIn()
+}
+
+// Acquire waits until the holder is let in or the context is done.
+// Every successful Acquire has to be followed by a Release.
+func (l *Limiter) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+
+	if l.held < l.limit && l.waiters.Len() == 0 {
+		l.held++
+		l.stats.Acquired++
+		l.mu.Unlock()
+
+		return nil
+	}
+
+	ready := make(chan struct{})
+	waiter := l.waiters.PushBack(ready)
+	l.stats.Contended++
+	l.mu.Unlock()
+
+	start := time.Now()
+
+	select {
+	case <-ready:
+		l.mu.Lock()
+		l.stats.WaitTime += time.Since(start)
+		l.mu.Unlock()
+
+		return nil
+
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.stats.WaitTime += time.Since(start)
+		l.stats.Canceled++
+
+		select {
+		case <-ready:
+			// It was let in right after the context was done, so the place is passed on.
+			l.held--
+			l.stats.Acquired--
+			l.letIn()
+		default:
+			l.waiters.Remove(waiter)
+		}
+
+		return ctx.Err()
+	}
+}
+
+// Release lets the next waiter in.
+func (l *Limiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held == 0 {
+		panic("limiter: release without acquire")
+	}
+
+	l.held--
+	l.letIn()
+}
+
+// Stats returns a copy of the counters.
+func (l *Limiter) Stats() LimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := l.stats
+	stats.Held = l.held
+	stats.Waiting = l.waiters.Len()
+
+	return stats
+}
+
+// letIn lets the waiters in while there is a place for them, l.mu has to be locked.
+func (l *Limiter) letIn() {
+	for l.held < l.limit && l.waiters.Len() > 0 {
+		ready := l.waiters.Remove(l.waiters.Front()).(chan struct{})
+		l.held++
+		l.stats.Acquired++
+		close(ready)
+	}
+}
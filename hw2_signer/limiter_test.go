@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterFIFO(t *testing.T) {
+	limiter := NewLimiter(1)
+	_ = limiter.Acquire(context.Background())
+
+	order := make(chan int, 5)
+
+	for i := 0; i < 5; i++ {
+		go func(i int) {
+			_ = limiter.Acquire(context.Background())
+			order <- i
+			limiter.Release()
+		}(i)
+
+		// Let the waiter get in the queue before the next one.
+		for limiter.Stats().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	limiter.Release()
+
+	got := make([]int, 0, 5)
+	for i := 0; i < 5; i++ {
+		got = append(got, <-order)
+	}
+
+	if expected := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", got, expected)
+	}
+
+	stats := limiter.Stats()
+	if stats.Acquired != 6 || stats.Contended != 5 || stats.Held != 0 || stats.WaitTime == 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	limiter := NewLimiter(2)
+	_ = limiter.Acquire(context.Background())
+	_ = limiter.Acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := limiter.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Raising the limit lets the waiter in at once.
+	acquired := make(chan struct{})
+	go func() {
+		_ = limiter.Acquire(context.Background())
+		close(acquired)
+	}()
+
+	for limiter.Stats().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	limiter.SetLimit(3)
+	<-acquired
+
+	stats := limiter.Stats()
+	if stats.Acquired != 3 || stats.Contended != 2 || stats.Canceled != 1 || stats.Held != 3 || stats.Waiting != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestMd5Signer(t *testing.T) {
+	defer func(lock func()) { OverheatLock = lock }(OverheatLock)
+
+	// The wrapped lock takes Md5Limiter as before, the signer waits in its own limiter.
+	lock := OverheatLock
+	OverheatLock = func() { lock() }
+
+	signer := &Md5Signer{Limiter: NewLimiter(1)}
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			signer.Sign("1")
+		}()
+	}
+
+	wg.Wait()
+
+	if stats := signer.Limiter.Stats(); stats.Acquired != 3 || stats.Held != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	// The waiter gives up when the context is done.
+	_ = signer.Limiter.Acquire(context.Background())
+	defer signer.Limiter.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := signer.SignContext(ctx, "1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error of the context, got: %v", err)
+	}
+}
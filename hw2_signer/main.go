@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+)
+
+const usage = "usage go run . [--per-item] [--salt salt] [-j n] [--single chain] [--multi signer] [--rounds n] [--cache size] [file ...]"
+
+// options of the command.
+type options struct {
+	perItem bool
+	salt    string
+	workers int
+	single  string
+	multi   string
+	rounds  int
+	cache   int
+}
+
+func main() {
+	opts := options{}
+	flags := newFlagSet(&opts)
+	_ = flags.Parse(os.Args[1:])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	out := bufio.NewWriter(os.Stdout)
+
+	err := run(ctx, out, os.Stdin, flags.Args(), opts)
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newFlagSet(opts *options) *flag.FlagSet {
+	flags := flag.NewFlagSet("signer", flag.ExitOnError)
+	flags.BoolVar(&opts.perItem, "per-item", false, "print the SingleHash and MultiHash of every item before the combined result")
+	flags.StringVar(&opts.salt, "salt", "", "`salt` added to the data of every hash")
+	flags.IntVar(&opts.workers, "j", MaxInputDataLen, "hash up to `n` items at once in every stage, zero means no limit")
+	flags.StringVar(&opts.single, "single", "crc32~crc32(md5)", "`chain` of the signers of SingleHash")
+	flags.StringVar(&opts.multi, "multi", "crc32", "`signer` of MultiHash")
+	flags.IntVar(&opts.rounds, "rounds", 6, "number of the signatures concatenated by MultiHash")
+	flags.IntVar(&opts.cache, "cache", 0, "memoize up to `size` signatures of every signer, zero means no cache")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// run signs the lines of the files, or of the stdin if there are no files.
+// Every line is an item, the empty ones are skipped.
+func run(ctx context.Context, out io.Writer, stdin io.Reader, paths []string, opts options) error {
+	scheme, err := ParseScheme(opts.single, opts.multi, opts.rounds)
+	if err != nil {
+		return err
+	}
+
+	if opts.cache > 0 {
+		scheme = scheme.Cached(opts.cache)
+	}
+
+	DataSignerSalt = opts.salt
+
+	input := Lines(stdin)
+	if len(paths) > 0 {
+		input = Files(paths...)
+	}
+
+	if opts.perItem {
+		return printItems(ctx, out, input, scheme, opts.workers)
+	}
+
+	stage := Then(Then(Then(input, scheme.stringStage(opts.workers)), scheme.MultiHashStage(opts.workers)), CombineResultsStage)
+
+	return Run(ctx, stage, func(result string) {
+		fmt.Fprintln(out, result)
+	})
+}
+
+// printItems prints every item with its SingleHash and MultiHash in the order of the input,
+// the combined result is the last line.
+func printItems(ctx context.Context, out io.Writer, input Stage[struct{}, string], scheme *Scheme, workers int) error {
+	type item struct {
+		data, single, multi string
+	}
+
+	hash := OrderedMapN(func(data string) item {
+		single, _ := scheme.SingleHashContext(ctx, normalize(data))
+		multi, _ := scheme.MultiHashContext(ctx, single)
+		return item{data: data, single: single, multi: multi}
+	}, workers)
+
+	multis := make([]string, 0)
+
+	err := Run(ctx, Then(input, hash), func(item0 item) {
+		fmt.Fprintf(out, "%s\t%s\t%s\n", item0.data, item0.single, item0.multi)
+		multis = append(multis, item0.multi)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, combine(multis))
+
+	return err
+}
+
+// stringStage is the SingleHash stage of the lines instead of the numbers.
+func (s *Scheme) stringStage(workers int) Stage[string, string] {
+	return func(ctx context.Context, in chan string, out chan string) error {
+		return MapN(func(data string) string {
+			result, _ := s.SingleHashContext(ctx, normalize(data))
+			return result
+		}, workers)(ctx, in, out)
+	}
+}
+
+// normalize makes the numbers to be signed the same way as SingleHash does, e.g. 007 is 7.
+func normalize(data string) string {
+	if num, err := strconv.Atoi(data); err == nil {
+		return strconv.Itoa(num)
+	}
+
+	return data
+}
+
+// Lines makes a stage which sends the non-empty lines of the reader.
+func Lines(r io.Reader) Stage[struct{}, string] {
+	return func(ctx context.Context, in chan struct{}, out chan string) error {
+		scanner := bufio.NewScanner(r)
+
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			if err := send(ctx, out, line); err != nil {
+				return err
+			}
+		}
+
+		return scanner.Err()
+	}
+}
+
+// Files makes a stage which sends the non-empty lines of the files one after another.
+func Files(paths ...string) Stage[struct{}, string] {
+	return func(ctx context.Context, in chan struct{}, out chan string) error {
+		for _, path := range paths {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+
+			err = Lines(file)(ctx, in, out)
+			file.Close()
+
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Observer is told about every value passing through the jobs of a pipeline,
+// the jobs are numbered by their position. It's called from many goroutines at once.
+type Observer interface {
+	// Start is called once before the pipeline is run.
+	Start(names []string)
+	// ItemIn is called when the job takes a value from its input.
+	ItemIn(stage int)
+	// ItemOut is called when the value sent by the job is taken by the next one.
+	// The latency is counted from the earliest input value which has no output yet,
+	// or from the previous output when there are no such values, e.g. for a generator.
+	// The blocked is the time spent waiting for the next job to take the value.
+	ItemOut(stage int, latency, blocked time.Duration)
+}
+
+// PipelineObserver is used by ExecutePipeline and ExecutePipelineContext, nil means nobody.
+var PipelineObserver Observer
+
+// observe passes the values of the job through the channels which tell the observer about them.
+func observe(job0 ctxJob, stage int, observer Observer) ctxJob {
+	return func(ctx context.Context, in, out chan interface{}) error {
+		jobIn := make(chan interface{})
+		jobOut := make(chan interface{})
+		done := make(chan struct{})
+		wg := &sync.WaitGroup{}
+
+		mu := &sync.Mutex{}
+		last := time.Now()
+		taken := make([]time.Time, 0)
+
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			defer close(jobIn)
+
+			for {
+				var value interface{}
+
+				// The job doesn't need the input after it's done, the rest of it is drained by ThenBuffered.
+				select {
+				case next, ok := <-in:
+					if !ok {
+						return
+					}
+					value = next
+				case <-done:
+					return
+				}
+
+				select {
+				case jobIn <- value:
+				case <-done:
+					return
+				}
+
+				mu.Lock()
+				taken = append(taken, time.Now())
+				mu.Unlock()
+
+				observer.ItemIn(stage)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for value := range jobOut {
+				sent := time.Now()
+
+				mu.Lock()
+				from := last
+				if len(taken) > 0 {
+					from, taken = taken[0], taken[1:]
+				}
+				last = sent
+				mu.Unlock()
+
+				if err := send(ctx, out, value); err != nil {
+					continue // Drain the output, nobody needs it anymore.
+				}
+
+				observer.ItemOut(stage, sent.Sub(from), time.Since(sent))
+			}
+		}()
+
+		err := job0(ctx, jobIn, jobOut)
+		close(jobOut)
+		close(done)
+		wg.Wait()
+
+		return err
+	}
+}
+
+// funcName is the name of the function without the package, e.g. SingleHash.
+func funcName(fn interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+
+	return name[strings.Index(name, ".")+1:]
+}
+
+// StageMetrics are the totals of a job collected by Summary.
+type StageMetrics struct {
+	Name       string
+	In, Out    int
+	Latency    time.Duration // Sum of the latencies of all the output values.
+	MaxLatency time.Duration
+	Blocked    time.Duration // Sum of the time spent waiting for the next job.
+}
+
+// Summary collects the metrics of every job to print them as a table.
+type Summary struct {
+	mu     sync.Mutex
+	stages []StageMetrics
+}
+
+// NewSummary makes an empty Summary, it's filled by the next pipeline.
+func NewSummary() *Summary {
+	return &Summary{}
+}
+
+func (s *Summary) Start(names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stages = make([]StageMetrics, len(names))
+	for i, name := range names {
+		s.stages[i].Name = name
+	}
+}
+
+func (s *Summary) ItemIn(stage int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stages[stage].In++
+}
+
+func (s *Summary) ItemOut(stage int, latency, blocked time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics := &s.stages[stage]
+	metrics.Out++
+	metrics.Latency += latency
+	metrics.Blocked += blocked
+
+	if latency > metrics.MaxLatency {
+		metrics.MaxLatency = latency
+	}
+}
+
+// Metrics returns a copy of the metrics of every job.
+func (s *Summary) Metrics() []StageMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]StageMetrics(nil), s.stages...)
+}
+
+// WriteTo prints the metrics as a table, a row per job.
+func (s *Summary) WriteTo(out io.Writer) (int64, error) {
+	counter := &countWriter{w: out}
+	table := tabwriter.NewWriter(counter, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(table, "#\tstage\tin\tout\tavg latency\tmax latency\tblocked")
+
+	for i, metrics := range s.Metrics() {
+		avg := time.Duration(0)
+		if metrics.Out > 0 {
+			avg = metrics.Latency / time.Duration(metrics.Out)
+		}
+
+		fmt.Fprintf(table, "%d\t%s\t%d\t%d\t%s\t%s\t%s\n", i, metrics.Name, metrics.In, metrics.Out,
+			avg.Round(time.Microsecond), metrics.MaxLatency.Round(time.Microsecond), metrics.Blocked.Round(time.Microsecond))
+	}
+
+	err := table.Flush()
+
+	return counter.n, err
+}
+
+func (s *Summary) String() string {
+	b := &strings.Builder{}
+	_, _ = s.WriteTo(b)
+
+	return b.String()
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+
+	return n, err
+}
+
+// ExpvarObserver publishes the metrics of every job as an expvar map,
+// e.g. {"0": {"name": "SingleHash", "in": 7, "out": 7, "latency_ns": ..., "blocked_ns": ...}}.
+// The latencies are the sums, so the rates of them can be graphed.
+type ExpvarObserver struct {
+	vars   *expvar.Map
+	mu     sync.Mutex
+	stages []*expvar.Map
+}
+
+// NewExpvarObserver publishes the map by the name, the map is reused if it's published already.
+func NewExpvarObserver(name string) *ExpvarObserver {
+	vars, ok := expvar.Get(name).(*expvar.Map)
+	if !ok {
+		vars = expvar.NewMap(name)
+	}
+
+	return &ExpvarObserver{vars: vars}
+}
+
+func (e *ExpvarObserver) Start(names []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.vars.Init()
+	e.stages = make([]*expvar.Map, len(names))
+
+	for i, name := range names {
+		stage := new(expvar.Map).Init()
+
+		stageName := &expvar.String{}
+		stageName.Set(name)
+		stage.Set("name", stageName)
+
+		e.stages[i] = stage
+		e.vars.Set(strconv.Itoa(i), stage)
+	}
+}
+
+func (e *ExpvarObserver) stage(i int) *expvar.Map {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.stages[i]
+}
+
+func (e *ExpvarObserver) ItemIn(stage int) {
+	e.stage(stage).Add("in", 1)
+}
+
+func (e *ExpvarObserver) ItemOut(stage int, latency, blocked time.Duration) {
+	vars := e.stage(stage)
+	vars.Add("out", 1)
+	vars.Add("latency_ns", int64(latency))
+	vars.Add("blocked_ns", int64(blocked))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+)
+
+// ctxJob is a pipeline stage which can fail, it has to stop as soon as the context is done.
+type ctxJob func(ctx context.Context, in, out chan interface{}) error
+
+// PipelineOptions tune ExecutePipelineWith.
+type PipelineOptions struct {
+	Buffer   int      // Capacity of the channels between the jobs.
+	Observer Observer // Told about the values passing through the jobs, nil means nobody.
+	Names    []string // Names of the jobs for the observer, their function names by default.
+}
+
+// ExecutePipelineContext runs the jobs as a pipeline until all of them are finished.
+// The first error of a job or the cancellation of the context stops every job and is returned.
+func ExecutePipelineContext(ctx context.Context, jobs ...ctxJob) error {
+	return ExecutePipelineWith(ctx, PipelineOptions{Buffer: DefaultLimits.Buffer, Observer: PipelineObserver}, jobs...)
+}
+
+// ExecutePipelineWith is the same as ExecutePipelineContext, but tuned by the options.
+func ExecutePipelineWith(ctx context.Context, opts PipelineOptions, jobs ...ctxJob) error {
+	if len(jobs) == 0 {
+		return ctx.Err()
+	}
+
+	if opts.Observer != nil {
+		names := make([]string, len(jobs))
+
+		for i, job0 := range jobs {
+			if i < len(opts.Names) {
+				names[i] = opts.Names[i]
+			} else {
+				names[i] = funcName(job0)
+			}
+		}
+
+		opts.Observer.Start(names)
+
+		observed := make([]ctxJob, 0, len(jobs))
+
+		for i, job0 := range jobs {
+			observed = append(observed, observe(job0, i, opts.Observer))
+		}
+
+		jobs = observed
+	}
+
+	stage := Stage[interface{}, interface{}](jobs[0])
+
+	for _, job0 := range jobs[1:] {
+		stage = ThenBuffered(stage, Stage[interface{}, interface{}](job0), opts.Buffer)
+	}
+
+	return Run(ctx, stage, nil)
+}
+
+// contextJob adapts the plain job which never fails and stops only when its input is over.
+func contextJob(job0 job) ctxJob {
+	return func(ctx context.Context, in, out chan interface{}) error {
+		job0(in, out)
+		return nil
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipelineContextError(t *testing.T) {
+	testPipelineContextError(t, nil)
+}
+
+func TestPipelineContextErrorObserved(t *testing.T) {
+	testPipelineContextError(t, NewSummary())
+}
+
+func testPipelineContextError(t *testing.T, observer Observer) {
+	goroutines := runtime.NumGoroutine()
+	errStage := errors.New("stage failed")
+
+	ctxJobs := []ctxJob{
+		// Endless input, it stops only because the pipeline is stopped.
+		func(ctx context.Context, in, out chan interface{}) error {
+			for i := 0; ; i++ {
+				if err := send[interface{}](ctx, out, i); err != nil {
+					return err
+				}
+			}
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for val := range in {
+				if val.(int) == 3 {
+					return errStage
+				}
+				if err := send(ctx, out, val); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		contextJob(func(in, out chan interface{}) {
+			for range in {
+			}
+		}),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		opts := PipelineOptions{Buffer: DefaultLimits.Buffer, Observer: observer}
+		errs <- ExecutePipelineWith(context.Background(), opts, ctxJobs...)
+	}()
+
+	select {
+	case err := <-errs:
+		if err != errStage {
+			t.Errorf("expected the error of the stage, got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the pipeline isn't stopped by the error")
+	}
+
+	// The goroutines of the jobs may still be exiting right after they are done.
+	leaked := runtime.NumGoroutine() - goroutines
+	for try := 0; try < 10 && leaked > 0; try++ {
+		time.Sleep(10 * time.Millisecond)
+		leaked = runtime.NumGoroutine() - goroutines
+	}
+	if leaked > 0 {
+		t.Errorf("%d goroutines leaked", leaked)
+	}
+}
+
+func TestPipelineContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ctxJobs := []ctxJob{
+		func(ctx context.Context, in, out chan interface{}) error {
+			for {
+				if err := send(ctx, out, 1); err != nil {
+					return err
+				}
+			}
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for range in {
+				time.Sleep(time.Millisecond)
+			}
+			return nil
+		},
+	}
+
+	start := time.Now()
+	err := ExecutePipelineContext(ctx, ctxJobs...)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected the deadline error, got: %v", err)
+	}
+
+	if end := time.Since(start); end > time.Second {
+		t.Errorf("pipeline is not stopped in time: %s", end)
+	}
+}
+
+func TestTypedPipeline(t *testing.T) {
+	testExpected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+	testResult := "NOT_SET"
+
+	// A stage with the wrong input type, e.g. MultiHashStage right after Values[int],
+	// doesn't compile at all instead of failing at runtime.
+	stage := Then(Then(Then(Values(0, 1), SingleHashStage), MultiHashStage), CombineResultsStage)
+
+	err := Run(context.Background(), stage, func(result string) {
+		testResult = result
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if testResult != testExpected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", testResult, testExpected)
+	}
+}
+
+func TestOrderedMap(t *testing.T) {
+	// The first values are the slowest ones, so they are ready last.
+	slow := func(i int) int {
+		time.Sleep(time.Duration(10-i) * 5 * time.Millisecond)
+		return i * i
+	}
+
+	var ordered, unordered []int
+
+	err := Run(context.Background(), Then(Values(0, 1, 2, 3, 4, 5, 6, 7, 8, 9), OrderedMap(slow)), func(v int) {
+		ordered = append(ordered, v)
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = Run(context.Background(), Then(Values(0, 1, 2, 3, 4, 5, 6, 7, 8, 9), Map(slow)), func(v int) {
+		unordered = append(unordered, v)
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := []int{0, 1, 4, 9, 16, 25, 36, 49, 64, 81}
+	if !reflect.DeepEqual(ordered, expected) {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", ordered, expected)
+	}
+
+	if len(unordered) != len(expected) {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", unordered, expected)
+	}
+}
+
+func TestMapLimit(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	fn := func(i int) int {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+
+		return i
+	}
+
+	for _, stage := range []Stage[int, int]{MapN(fn, 3), OrderedMapN(fn, 3)} {
+		atomic.StoreInt32(&maxInFlight, 0)
+		count := 0
+
+		err := Run(context.Background(), Then(Values(0, 1, 2, 3, 4, 5, 6, 7, 8, 9), stage), func(int) {
+			count++
+		})
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if count != 10 || maxInFlight > 3 {
+			t.Errorf("results not match\nGot: %d values, %d at once\nExpected: 10 values, 3 at once", count, maxInFlight)
+		}
+	}
+}
+
+func TestPipelineObserver(t *testing.T) {
+	generate := func(ctx context.Context, in, out chan interface{}) error {
+		for i := 0; i < 5; i++ {
+			if err := send[interface{}](ctx, out, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	double := func(ctx context.Context, in, out chan interface{}) error {
+		for value := range in {
+			time.Sleep(10 * time.Millisecond)
+			if err := send[interface{}](ctx, out, value.(int)*2); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	sum := 0
+	collect := func(ctx context.Context, in, out chan interface{}) error {
+		for value := range in {
+			sum += value.(int)
+		}
+		return nil
+	}
+
+	summary := NewSummary()
+	vars := NewExpvarObserver("test_pipeline")
+
+	for _, observer := range []Observer{summary, vars} {
+		opts := PipelineOptions{Observer: observer, Names: []string{"generate", "double", "collect"}}
+		if err := ExecutePipelineWith(context.Background(), opts, generate, double, collect); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	if sum != 40 {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", sum, 40)
+	}
+
+	metrics := summary.Metrics()
+	got := fmt.Sprintf("%s %d/%d, %s %d/%d, %s %d/%d",
+		metrics[0].Name, metrics[0].In, metrics[0].Out,
+		metrics[1].Name, metrics[1].In, metrics[1].Out,
+		metrics[2].Name, metrics[2].In, metrics[2].Out)
+	expected := "generate 0/5, double 5/5, collect 5/0"
+	if got != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", got, expected)
+	}
+
+	if metrics[1].MaxLatency < 10*time.Millisecond || metrics[0].Blocked == 0 {
+		t.Errorf("latencies are not counted: %+v", metrics)
+	}
+
+	if !strings.Contains(summary.String(), "double") {
+		t.Errorf("no stage in the summary:\n%s", summary)
+	}
+
+	double0 := vars.vars.Get("1").(*expvar.Map)
+	got = fmt.Sprintf("%s %s %s", double0.Get("name"), double0.Get("in"), double0.Get("out"))
+	expected = `"double" 5 5`
+	if got != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", got, expected)
+	}
+}
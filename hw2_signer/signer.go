@@ -1,33 +1,23 @@
 package main
 
 import (
-	"fmt"
-	"runtime"
+	"context"
 	"sort"
-	"strconv"
 	"strings"
-	"sync"
-	"time"
 )
 
+// ExecutePipeline runs the plain jobs which can neither fail nor be cancelled.
 func ExecutePipeline(jobs ...job) {
-	in := make(chan interface{}, 0) // Only required as a placeholder for the first job.
-	out := make(chan interface{})
-	_runJob := func(job0 job, in0, out0 chan interface{}) {
-		job0(in0, out0)
-		close(out0) // Break channel range loop when job is finished.
-	}
+	ctxJobs := make([]ctxJob, 0, len(jobs))
+	names := make([]string, 0, len(jobs))
 
-	// Initialize all the jobs.
 	for _, job0 := range jobs {
-		go _runJob(job0, in, out)
-		in = out // Bypass data.
-		out = make(chan interface{})
+		ctxJobs = append(ctxJobs, contextJob(job0))
+		names = append(names, funcName(job0))
 	}
 
-	for range in { // Wait until last job is finished.
-		<-in
-	}
+	opts := PipelineOptions{Buffer: DefaultLimits.Buffer, Observer: PipelineObserver, Names: names}
+	_ = ExecutePipelineWith(context.Background(), opts, ctxJobs...)
 }
 
 // SingleHash считает значение crc32(data)+"~"+crc32(md5(data))
@@ -36,46 +26,39 @@ func ExecutePipeline(jobs ...job) {
 // crc32 считается через функцию DataSignerCrc32
 // md5 считается через DataSignerMd5
 func SingleHash(in, out chan interface{}) {
-	wg := &sync.WaitGroup{}
-	lock := make(chan int, 1)
-
-	for raw := range in {
-		wg.Add(1)
-		num, ok := raw.(int)
-
-		if !ok {
-			fmt.Println("SingleHash: Error: can't convert input to integer")
-		}
-
-		str := strconv.Itoa(num)
-		crc32 := make(chan string, 1)
-		md5 := make(chan string, 1)
-
-		// Do the parallel calculation of crc32(data).
-		go func(ch chan string, str0 string) {
-			ch <- DataSignerCrc32(str)
-		}(crc32, str)
-
-		// Do the parallel calculation of crc32(md5(data)).
-		go func(ch chan string, str0 string) {
-			// Limit parallel execution.
-			lock <- 1
-			md5Str := DataSignerMd5(str)
-			<-lock
-
-			md5 <- DataSignerCrc32(md5Str)
-		}(md5, str)
-
-		// Concat the result and pass further.
-		go func(ch1 chan string, ch2 chan string) {
-			defer wg.Done()
-			result := <-ch1 + "~" + <-ch2
-			out <- result
-		}(crc32, md5)
-	}
+	untyped("SingleHash", SingleHashStage)(in, out)
+}
+
+// Limits bound the number of values hashed by the stages at once,
+// so the number of DataSignerCrc32 calls in flight is bounded too.
+// Zero or less means no limit.
+type Limits struct {
+	SingleHash int // Every value makes 2 calls.
+	MultiHash  int // Every value makes 6 calls.
+	Buffer     int // Capacity of the channels between the stages.
+}
+
+// DefaultLimits are used by the stages and ExecutePipeline,
+// they let MaxInputDataLen values be hashed at once.
+var DefaultLimits = Limits{
+	SingleHash: MaxInputDataLen,
+	MultiHash:  MaxInputDataLen,
+	Buffer:     MaxInputDataLen,
+}
 
-	wg.Wait()
-	close(lock)
+// SingleHashStage is the typed SingleHash, the results go in the order they are ready.
+func SingleHashStage(ctx context.Context, in chan int, out chan string) error {
+	return NewSingleHashStage(DefaultLimits.SingleHash)(ctx, in, out)
+}
+
+// NewSingleHashStage makes SingleHashStage which hashes no more than workers values at once.
+func NewSingleHashStage(workers int) Stage[int, string] {
+	return DefaultScheme.SingleHashStage(workers)
+}
+
+// SingleHashOf calculates crc32(data) in parallel with crc32(md5(data)).
+func SingleHashOf(num int) string {
+	return DefaultScheme.SingleHash(num)
 }
 
 // MultiHash считает значение crc32(th+data))
@@ -84,65 +67,45 @@ func SingleHash(in, out chan interface{}) {
 // потом берёт конкатенацию результатов в порядке расчета (0..5),
 // где data - то что пришло на вход (и ушло на выход из SingleHash)
 func MultiHash(in, out chan interface{}) {
-	wg := &sync.WaitGroup{}
-
-	for raw := range in {
-		wg.Add(1)
-		str, ok := raw.(string)
-
-		if !ok {
-			fmt.Println("MultiHash: Error: can't convert input to string")
-		}
-
-		ch := make(chan string, 6)
-
-		runtime.GOMAXPROCS(6)
-
-		for th := 0; th < 6; th++ {
-			runtime.Gosched()
-			// Do the parallel calculation.
-			go func(th0 int, str0 string, ch0 chan string) {
-				res := DataSignerCrc32(strconv.Itoa(th0) + str0)
-				time.Sleep(time.Duration(th0) * 5 * time.Millisecond) // Preserve loop order.
-				ch0 <- res
-			}(th, str, ch)
-		}
-
-		// Collect result of calculations, concat it and pass further.
-		go func(ch0 chan string) {
-			defer wg.Done()
-			defer close(ch)
-
-			result := ""
+	untyped("MultiHash", MultiHashStage)(in, out)
+}
 
-			for th0 := 0; th0 < 6; th0++ {
-				result += <-ch
-			}
+// MultiHashStage is the typed MultiHash, the results go in the order they are ready.
+func MultiHashStage(ctx context.Context, in chan string, out chan string) error {
+	return NewMultiHashStage(DefaultLimits.MultiHash)(ctx, in, out)
+}
 
-			out <- result
-		}(ch)
-	}
+// NewMultiHashStage makes MultiHashStage which hashes no more than workers values at once.
+func NewMultiHashStage(workers int) Stage[string, string] {
+	return DefaultScheme.MultiHashStage(workers)
+}
 
-	wg.Wait()
+// MultiHashOf calculates all the crc32(th+data) in parallel.
+func MultiHashOf(str string) string {
+	return DefaultScheme.MultiHash(str)
 }
 
 // CombineResults получает все результаты, сортирует (https://golang.org/pkg/sort/),
 // объединяет отсортированный результат через _ (символ подчеркивания) в одну строку
 func CombineResults(in, out chan interface{}) {
+	untyped("CombineResults", CombineResultsStage)(in, out)
+}
+
+// CombineResultsStage is the typed CombineResults.
+func CombineResultsStage(ctx context.Context, in chan string, out chan string) error {
 	var values []string
 
 	// Combine all of the results.
-	for raw := range in {
-		str, ok := raw.(string)
-
-		if !ok {
-			fmt.Println("CombineResults: Error: can't convert input to integer")
-		}
-
+	for str := range in {
 		values = append(values, str)
 	}
 
+	return send(ctx, out, combine(values))
+}
+
+// combine sorts the values and joins them by "_".
+func combine(values []string) string {
 	sort.Strings(values)
 
-	out <- strings.Join(values, "_")
+	return strings.Join(values, "_")
 }
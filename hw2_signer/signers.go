@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Signer hashes the data into a printable signature.
+type Signer interface {
+	Sign(data string) string
+}
+
+// SignerFunc makes a Signer of the function.
+type SignerFunc func(data string) string
+
+func (f SignerFunc) Sign(data string) string {
+	return f(data)
+}
+
+// ContextSigner is the Signer which may wait for its turn, it gives up when the context is done.
+type ContextSigner interface {
+	Signer
+	SignContext(ctx context.Context, data string) (string, error)
+}
+
+// signContext signs by SignContext if the signer has it, the other ones can't be stopped.
+func signContext(ctx context.Context, signer Signer, data string) (string, error) {
+	if signer, ok := signer.(ContextSigner); ok {
+		return signer.SignContext(ctx, data)
+	}
+
+	return signer.Sign(data), nil
+}
+
+// newSigner makes a comparable Signer of the function, so the caches of Scheme.Cached can find it.
+func newSigner(f func(data string) string) Signer {
+	signer := SignerFunc(f)
+	return &signer
+}
+
+// Signers are the known signers by their names. Every signer but the ones
+// of common.go is fast and adds DataSignerSalt to the data the same way they do.
+var Signers = map[string]Signer{
+	// The DataSigner variables may be replaced, so they are looked up on every call.
+	"crc32": newSigner(func(data string) string { return DataSignerCrc32(data) }),
+	"md5":   &Md5Signer{Limiter: NewLimiter(1)},
+
+	"crc32c": newSigner(func(data string) string {
+		sum := crc32.Checksum([]byte(data+DataSignerSalt), crc32.MakeTable(crc32.Castagnoli))
+		return strconv.FormatUint(uint64(sum), 10)
+	}),
+	"sha1": newSigner(func(data string) string {
+		return fmt.Sprintf("%x", sha1.Sum([]byte(data+DataSignerSalt)))
+	}),
+	"sha256": newSigner(func(data string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(data+DataSignerSalt)))
+	}),
+	"xxhash": newSigner(func(data string) string {
+		return fmt.Sprintf("%016x", xxhash64([]byte(data+DataSignerSalt), 0))
+	}),
+}
+
+// Md5Signer calls DataSignerMd5 letting only the limit of the Limiter in at once.
+// DataSignerMd5 guards itself by OverheatLock, but that can't be given up and may be
+// replaced by the one which doesn't wait fairly, e.g. the one of the tests spins for a second.
+// So the calls wait for their turn here, where the pipeline can stop waiting.
+type Md5Signer struct {
+	Limiter *Limiter
+}
+
+func (s *Md5Signer) Sign(data string) string {
+	signature, _ := s.SignContext(context.Background(), data)
+	return signature
+}
+
+func (s *Md5Signer) SignContext(ctx context.Context, data string) (string, error) {
+	if err := s.Limiter.Acquire(ctx); err != nil {
+		return "", err
+	}
+	defer s.Limiter.Release()
+
+	return DataSignerMd5(data), nil
+}
+
+// Scheme is the chain of signers used by SingleHash and MultiHash.
+type Scheme struct {
+	Single [][]Signer // Parts of SingleHash joined by "~", the signers of a part are applied from the last one.
+	Multi  Signer     // Signs th+data for every th of MultiHash, the results are concatenated.
+	Rounds int        // Number of the th of MultiHash.
+}
+
+// DefaultScheme is crc32(data)+"~"+crc32(md5(data)) for SingleHash
+// and crc32(th+data) with th=0..5 for MultiHash.
+var DefaultScheme = &Scheme{
+	Single: [][]Signer{{Signers["crc32"]}, {Signers["crc32"], Signers["md5"]}},
+	Multi:  Signers["crc32"],
+	Rounds: 6,
+}
+
+// ParseScheme makes a scheme of the names of the signers, e.g. DefaultScheme is
+// ParseScheme("crc32~crc32(md5)", "crc32", 6).
+func ParseScheme(single, multi string, rounds int) (*Scheme, error) {
+	scheme := &Scheme{Rounds: rounds}
+
+	if rounds <= 0 {
+		return nil, fmt.Errorf("bad number of rounds %d", rounds)
+	}
+
+	for _, part := range strings.Split(single, "~") {
+		names := strings.Split(strings.TrimRight(part, ")"), "(")
+		if len(part)-len(strings.TrimRight(part, ")")) != len(names)-1 {
+			return nil, fmt.Errorf("unbalanced parentheses in %q", part)
+		}
+
+		chain := make([]Signer, 0, len(names))
+
+		for _, name := range names {
+			signer, err := lookupSigner(name)
+			if err != nil {
+				return nil, err
+			}
+
+			chain = append(chain, signer)
+		}
+
+		scheme.Single = append(scheme.Single, chain)
+	}
+
+	var err error
+	scheme.Multi, err = lookupSigner(multi)
+
+	return scheme, err
+}
+
+func lookupSigner(name string) (Signer, error) {
+	signer, ok := Signers[strings.TrimSpace(name)]
+	if !ok {
+		names := make([]string, 0, len(Signers))
+		for name0 := range Signers {
+			names = append(names, name0)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("unknown signer %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	return signer, nil
+}
+
+// SingleHash calculates all the parts of the num in parallel.
+func (s *Scheme) SingleHash(num int) string {
+	return s.SingleHashString(strconv.Itoa(num))
+}
+
+// SingleHashString is the same as SingleHash, but for any data, not only the numbers.
+func (s *Scheme) SingleHashString(data string) string {
+	result, _ := s.SingleHashContext(context.Background(), data)
+	return result
+}
+
+// SingleHashContext is the same as SingleHashString, but the signers stop waiting for their turn
+// when the context is done, its error is returned then.
+func (s *Scheme) SingleHashContext(ctx context.Context, data string) (string, error) {
+	wg := &sync.WaitGroup{}
+	results := make([]string, len(s.Single))
+	errs := make([]error, len(s.Single))
+
+	for i, chain := range s.Single {
+		wg.Add(1)
+
+		go func(i int, chain []Signer) {
+			defer wg.Done()
+
+			result := data
+			for j := len(chain) - 1; j >= 0 && errs[i] == nil; j-- {
+				result, errs[i] = signContext(ctx, chain[j], result)
+			}
+
+			results[i] = result
+		}(i, chain)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(results, "~"), nil
+}
+
+// MultiHash calculates all the Multi(th+data) in parallel,
+// each result is put by its th index so the concatenation keeps the th order.
+func (s *Scheme) MultiHash(data string) string {
+	result, _ := s.MultiHashContext(context.Background(), data)
+	return result
+}
+
+// MultiHashContext is the same as MultiHash, but it stops waiting for the signer when the context is done.
+func (s *Scheme) MultiHashContext(ctx context.Context, data string) (string, error) {
+	wg := &sync.WaitGroup{}
+	results := make([]string, s.Rounds)
+	errs := make([]error, s.Rounds)
+
+	for th := range results {
+		wg.Add(1)
+
+		go func(th0 int) {
+			defer wg.Done()
+			results[th0], errs[th0] = signContext(ctx, s.Multi, strconv.Itoa(th0)+data)
+		}(th)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(results, ""), nil
+}
+
+// SingleHashStage makes a SingleHash stage which hashes no more than workers values at once.
+func (s *Scheme) SingleHashStage(workers int) Stage[int, string] {
+	return func(ctx context.Context, in chan int, out chan string) error {
+		return MapN(func(num int) string {
+			result, _ := s.SingleHashContext(ctx, strconv.Itoa(num))
+			return result
+		}, workers)(ctx, in, out)
+	}
+}
+
+// MultiHashStage makes a MultiHash stage which hashes no more than workers values at once.
+func (s *Scheme) MultiHashStage(workers int) Stage[string, string] {
+	return func(ctx context.Context, in chan string, out chan string) error {
+		return MapN(func(data string) string {
+			result, _ := s.MultiHashContext(ctx, data)
+			return result
+		}, workers)(ctx, in, out)
+	}
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxhash64 is the XXH64 hash of the data.
+func xxhash64(data []byte, seed uint64) uint64 {
+	n := len(data)
+	var h uint64
+
+	if n >= 32 {
+		v1 := seed + xxPrime1 + xxPrime2
+		v2 := seed + xxPrime2
+		v3 := seed
+		v4 := seed - xxPrime1
+
+		for ; len(data) >= 32; data = data[32:] {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(data[0:]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(data[8:]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(data[16:]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(data[24:]))
+		}
+
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMergeRound(h, v1)
+		h = xxMergeRound(h, v2)
+		h = xxMergeRound(h, v3)
+		h = xxMergeRound(h, v4)
+	} else {
+		h = seed + xxPrime5
+	}
+
+	h += uint64(n)
+
+	for ; len(data) >= 8; data = data[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(data))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+
+	if len(data) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(data)) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		data = data[4:]
+	}
+
+	for _, b := range data {
+		h ^= uint64(b) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+
+	return h
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+
+	return acc*xxPrime1 + xxPrime4
+}
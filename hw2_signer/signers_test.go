@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSigners(t *testing.T) {
+	testCases := []struct {
+		signer, data, expected string
+	}{
+		{"xxhash", "", "ef46db3751d8e999"},
+		{"xxhash", "a", "d24ec4f1a98c6e5b"},
+		{"xxhash", "abc", "44bc2cf5ad770999"},
+		{"xxhash", "Nobody inspects the spammish repetition", "fbcea83c8a378bf1"},
+		{"sha1", "abc", "a9993e364706816aba3e25717850c26c9cd0d89d"},
+		{"sha256", "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"crc32c", "123456789", "3808858755"},
+	}
+
+	for _, testCase := range testCases {
+		result := Signers[testCase.signer].Sign(testCase.data)
+		if result != testCase.expected {
+			t.Errorf("%s(%q) results not match\nGot: %v\nExpected: %v", testCase.signer, testCase.data, result, testCase.expected)
+		}
+	}
+}
+
+func TestScheme(t *testing.T) {
+	scheme, err := ParseScheme("sha1~xxhash(sha256)", "crc32c", 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var result string
+
+	stage := Then(Then(Values(1), scheme.SingleHashStage(1)), scheme.MultiHashStage(1))
+	err = Run(context.Background(), stage, func(value string) {
+		result = value
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	single := Signers["sha1"].Sign("1") + "~" + Signers["xxhash"].Sign(Signers["sha256"].Sign("1"))
+	expected := Signers["crc32c"].Sign("0"+single) + Signers["crc32c"].Sign("1"+single)
+	if result != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+
+	cached := scheme.Cached(10)
+	for i := 0; i < 2; i++ {
+		if result := cached.MultiHash(cached.SingleHash(1)); result != expected {
+			t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+		}
+	}
+
+	if hits, misses := cached.Multi.(*Cache).Stats(); hits != 2 || misses != 2 {
+		t.Errorf("results not match\nGot: %d hits, %d misses\nExpected: 2 hits, 2 misses", hits, misses)
+	}
+
+	// crc32 is used three times by DefaultScheme, but it's signed once.
+	shared := DefaultScheme.Cached(10)
+	if shared.Multi != shared.Single[0][0] || shared.Multi != shared.Single[1][0] {
+		t.Errorf("the same signer got several caches")
+	}
+
+	for _, bad := range []string{"crc32~crc32(md5", "crc64", "crc32()"} {
+		if _, err := ParseScheme(bad, "crc32", 6); err == nil {
+			t.Errorf("no error for the scheme %q", bad)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	var calls int32
+	signer := SignerFunc(func(data string) string {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(20 * time.Millisecond)
+		return "#" + data
+	})
+
+	cache := NewCache(signer, 2)
+	wg := &sync.WaitGroup{}
+
+	// The concurrent calls share a single one.
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			if result := cache.Sign("a"); result != "#a" {
+				t.Errorf("results not match\nGot: %v\nExpected: %v", result, "#a")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// c evicts b, the least recently used one, so b is signed again.
+	for _, data := range []string{"b", "a", "c", "a", "b"} {
+		cache.Sign(data)
+	}
+
+	hits, misses := cache.Stats()
+	if calls != 4 || hits != 11 || misses != 4 {
+		t.Errorf("results not match\nGot: %d calls, %d hits, %d misses\nExpected: 4 calls, 11 hits, 4 misses", calls, hits, misses)
+	}
+}
+
+func TestCacheSalt(t *testing.T) {
+	defer func(salt string) { DataSignerSalt = salt }(DataSignerSalt)
+
+	cache := NewCache(Signers["sha1"], 0)
+
+	for _, salt := range []string{"", "salt"} {
+		DataSignerSalt = salt
+		if result, expected := cache.Sign("a"), Signers["sha1"].Sign("a"); result != expected {
+			t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+		}
+	}
+}
+
+func TestCachePanic(t *testing.T) {
+	var calls int32
+	started, release := make(chan struct{}), make(chan struct{})
+	signer := SignerFunc(func(data string) string {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+			<-release
+			panic("overheat")
+		}
+		return "#" + data
+	})
+
+	cache := NewCache(signer, 0)
+
+	go func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("the panic of the signer is lost")
+			}
+		}()
+		cache.Sign("a")
+	}()
+
+	<-started
+
+	result := make(chan string)
+	go func() {
+		result <- cache.Sign("a")
+	}()
+
+	// The second call waits for the first one, which panics, and then signs the data itself.
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case got := <-result:
+		if got != "#a" {
+			t.Errorf("results not match\nGot: %v\nExpected: %v", got, "#a")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the waiter is blocked after the panic")
+	}
+}
+
+func TestSchemeContext(t *testing.T) {
+	scheme, err := ParseScheme("md5", "sha1", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// md5 is busy, so the stage waits for it until the pipeline is stopped.
+	limiter := Signers["md5"].(*Md5Signer).Limiter
+	_ = limiter.Acquire(context.Background())
+	defer limiter.Release()
+
+	canceled := limiter.Stats().Canceled
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	results := 0
+	err = Run(ctx, Then(Values(1, 2), scheme.SingleHashStage(0)), func(string) {
+		results++
+	})
+
+	if !errors.Is(err, context.DeadlineExceeded) || results != 0 {
+		t.Errorf("results not match\nGot: %v, %d results\nExpected: %v, 0 results", err, results, context.DeadlineExceeded)
+	}
+
+	if stats := limiter.Stats(); stats.Canceled-canceled != 2 || stats.Waiting != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
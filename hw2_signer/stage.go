@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Stage is a typed pipeline step, the stages can be chained by Then only
+// when the output type of the first one is the input type of the second one.
+// The stage has to stop as soon as the context is done, its output is closed when it returns.
+type Stage[In, Out any] func(ctx context.Context, in chan In, out chan Out) error
+
+// Then chains the stages, the first error of any of them stops both and is returned.
+func Then[A, B, C any](first Stage[A, B], second Stage[B, C]) Stage[A, C] {
+	return ThenBuffered(first, second, 0)
+}
+
+// ThenBuffered is the same as Then, but the first stage may get up to size values
+// ahead of the second one before it's blocked.
+func ThenBuffered[A, B, C any](first Stage[A, B], second Stage[B, C], size int) Stage[A, C] {
+	return func(ctx context.Context, in chan A, out chan C) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		once := &sync.Once{}
+		var firstErr error
+		fail := func(err error) {
+			once.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}
+
+		mid := make(chan B, size)
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			defer close(mid) // Break channel range loop when the stage is finished.
+
+			if err := first(ctx, in, mid); err != nil {
+				fail(err)
+			}
+		}()
+
+		if err := second(ctx, mid, out); err != nil {
+			fail(err)
+		}
+
+		// Unblock the first stage if the second one quits before its input is over.
+		for range mid {
+		}
+		<-done
+
+		return firstErr
+	}
+}
+
+// Values is the first stage which passes the values one by one.
+func Values[T any](values ...T) Stage[struct{}, T] {
+	return func(ctx context.Context, in chan struct{}, out chan T) error {
+		for _, value := range values {
+			if err := send(ctx, out, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Map makes a stage which applies the fn to every input value in its own goroutine,
+// the results are passed further in the order they are ready.
+func Map[In, Out any](fn func(In) Out) Stage[In, Out] {
+	return mapStage(fn, 0, false)
+}
+
+// OrderedMap is the same as Map, but the results are passed further in the order of the input.
+func OrderedMap[In, Out any](fn func(In) Out) Stage[In, Out] {
+	return mapStage(fn, 0, true)
+}
+
+// MapN is the same as Map, but no more than workers values are processed at once,
+// the next input value isn't read until one of them is sent further.
+// Zero or less workers means no limit.
+func MapN[In, Out any](fn func(In) Out, workers int) Stage[In, Out] {
+	return mapStage(fn, workers, false)
+}
+
+// OrderedMapN is the same as MapN, but the results are passed further in the order of the input.
+func OrderedMapN[In, Out any](fn func(In) Out, workers int) Stage[In, Out] {
+	return mapStage(fn, workers, true)
+}
+
+func mapStage[In, Out any](fn func(In) Out, workers int, ordered bool) Stage[In, Out] {
+	return func(ctx context.Context, in chan In, out chan Out) error {
+		wg := &sync.WaitGroup{}
+
+		var slots chan struct{}
+		if workers > 0 {
+			slots = make(chan struct{}, workers)
+		}
+
+		// Every result waits for the previous one to be sent when the order is kept.
+		// The earliest value always holds a slot, so the limit can't lock the order.
+		prev := make(chan struct{})
+		close(prev)
+
+	loop:
+		for value := range in {
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					break loop
+				}
+			}
+
+			next := make(chan struct{})
+			wg.Add(1)
+
+			go func(value In, prev, next chan struct{}) {
+				defer wg.Done()
+				defer close(next)
+
+				result := fn(value)
+
+				if ordered {
+					<-prev
+				}
+
+				// The result of the fn given up because the pipeline is stopped isn't sent.
+				if ctx.Err() == nil {
+					_ = send(ctx, out, result)
+				}
+
+				if slots != nil {
+					<-slots
+				}
+			}(value, prev, next)
+
+			prev = next
+		}
+
+		wg.Wait()
+
+		return ctx.Err()
+	}
+}
+
+// Run executes the stage with an empty input and passes all its output to the sink.
+// The error of the stage or the one of the context is returned.
+func Run[In, Out any](ctx context.Context, stage Stage[In, Out], sink func(Out)) error {
+	in := make(chan In)
+	close(in) // The first stage has no input at all.
+
+	out := make(chan Out)
+	result := make(chan error, 1)
+
+	go func() {
+		defer close(out)
+		result <- stage(ctx, in, out)
+	}()
+
+	for value := range out { // Wait until the stage is finished.
+		if sink != nil {
+			sink(value)
+		}
+	}
+
+	if err := <-result; err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// send passes the value to the next stage unless the pipeline is stopped.
+func send[T any](ctx context.Context, out chan T, value T) error {
+	select {
+	case out <- value:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// untyped adapts the typed stage to the plain job,
+// the input values of a wrong type are reported and skipped.
+func untyped[In, Out any](name string, stage Stage[In, Out]) job {
+	return func(in, out chan interface{}) {
+		typedIn := make(chan In)
+		typedOut := make(chan Out)
+		done := make(chan struct{})
+
+		go func() {
+			defer close(typedIn)
+
+			for raw := range in {
+				value, ok := raw.(In)
+				if !ok {
+					fmt.Printf("%s: Error: can't convert input %T to %T\n", name, raw, value)
+					continue
+				}
+				typedIn <- value
+			}
+		}()
+
+		go func() {
+			defer close(done)
+
+			for value := range typedOut {
+				out <- value
+			}
+		}()
+
+		if err := stage(context.Background(), typedIn, typedOut); err != nil {
+			fmt.Printf("%s: Error: %v\n", name, err)
+		}
+
+		close(typedOut)
+		<-done
+
+		for range typedIn {
+		}
+	}
+}
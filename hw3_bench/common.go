@@ -13,15 +13,32 @@ import (
 
 const filePath string = "./data/users.txt"
 
-func SlowSearch(out io.Writer) {
-	file, err := os.Open(filePath)
+func SlowSearch(out io.Writer) error {
+	return SlowSearchFile(out, filePath)
+}
+
+// SlowSearchFile is SlowSearch of the file, it may be compressed by gzip or zstd.
+func SlowSearchFile(out io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return SlowSearchReader(out, file)
+}
+
+// SlowSearchReader is SlowSearch of the input of the reader, it may be compressed by gzip or zstd.
+func SlowSearchReader(out io.Writer, reader io.Reader) error {
+	input, err := Decompress(reader)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer input.Close()
 
-	fileContents, err := ioutil.ReadAll(file)
+	fileContents, err := ioutil.ReadAll(input)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	r := regexp.MustCompile("@")
@@ -37,7 +54,7 @@ func SlowSearch(out io.Writer) {
 		// fmt.Printf("%v %v\n", err, line)
 		err := json.Unmarshal([]byte(line), &user)
 		if err != nil {
-			panic(err)
+			return err
 		}
 		users = append(users, user)
 	}
@@ -106,6 +123,11 @@ func SlowSearch(out io.Writer) {
 		foundUsers += fmt.Sprintf("[%d] %s <%s>\n", i, user["name"], email)
 	}
 
-	fmt.Fprintln(out, "found users:\n"+foundUsers)
-	fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+	if _, err := fmt.Fprintln(out, "found users:\n"+foundUsers); err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, "Total unique browsers", len(seenBrowsers))
+
+	return err
 }
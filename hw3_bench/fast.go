@@ -19,71 +19,137 @@ type User struct {
 	Phone    string   `json:"-"`
 }
 
+// androidAndMSIE is the question of SlowSearch.
+var androidAndMSIE = MustParseQuery(`browsers contains "Android" and browsers contains "MSIE"`)
+
 // вам надо написать более быструю оптимальную этой функции
-func FastSearch(out io.Writer) {
-	fileReader, err := os.Open(filePath)
+func FastSearch(out io.Writer) error {
+	return Search(out, filePath, androidAndMSIE)
+}
+
+// Search prints the users of the file matching the query the same way SlowSearch does,
+// the browsers matching any condition of the query on them are counted as seen.
+// The gzip and zstd files are decompressed on the fly.
+func Search(out io.Writer, path string, query *Query) error {
+	return searchFile(out, path, query, false)
+}
+
+// SearchReader is the same as Search, but for the input of the reader.
+func SearchReader(out io.Writer, r io.Reader, query *Query) error {
+	return searchTo(out, r, query, false)
+}
+
+// FastSearchLexer is FastSearch which parses the lines by the lexer instead of easyjson.
+func FastSearchLexer(out io.Writer) error {
+	return SearchLexer(out, filePath, androidAndMSIE)
+}
+
+// SearchLexer is the same as Search, but the lines are parsed by the lexer
+// which allocates nothing but the found users and browsers.
+func SearchLexer(out io.Writer, path string, query *Query) error {
+	return searchFile(out, path, query, true)
+}
+
+// SearchReaderLexer is the same as SearchLexer, but for the input of the reader.
+func SearchReaderLexer(out io.Writer, r io.Reader, query *Query) error {
+	return searchTo(out, r, query, true)
+}
+
+func searchFile(out io.Writer, path string, query *Query, useLexer bool) error {
+	fileReader, err := os.Open(path)
 	if err != nil {
-		fmt.Printf("error: %v", err)
-		return
+		return err
 	}
+	defer fileReader.Close()
 
-	defer func() {
-		err := fileReader.Close()
-		if err != nil {
-			fmt.Printf("error: %v", err)
-			return
-		}
-	}()
+	return searchTo(out, fileReader, query, useLexer)
+}
 
-	scanner := bufio.NewScanner(fileReader)
-	browsers := map[string]bool{}
-	users := []string{}
-	counter := -1
+func searchTo(out io.Writer, r io.Reader, query *Query, useLexer bool) error {
+	input, err := Decompress(r)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
 
-	for scanner.Scan() {
-		bytes := scanner.Bytes()
-		user := &User{}
-		isAndroid := false
-		isMSIE := false
-		counter++
+	result, err := searchReader(input, query, useLexer)
+	if err != nil {
+		return err
+	}
+
+	return result.write(out)
+}
+
+// found is the result of the search in the whole file or in a chunk of it.
+type found struct {
+	lines    int
+	users    []foundUser
+	browsers map[string]bool
+}
+
+type foundUser struct {
+	index       int // Line of the user counting from 0.
+	name, email string
+}
+
+// searchReader finds the users matching the query in the JSON lines,
+// they are parsed by the lexer instead of easyjson if useLexer is set.
+func searchReader(r io.Reader, query *Query, useLexer bool) (*found, error) {
+	scanner := bufio.NewScanner(r)
+	result := &found{browsers: map[string]bool{}}
+	// The user is reused, the query makes it escape to the heap.
+	user := &User{}
+	lex := &lexer{}
+
+	// The strings of the lexer point into the line, so the kept ones are copied.
+	keep := func(str string) string {
+		if useLexer {
+			return strings.Clone(str)
+		}
+		return str
+	}
+
+	for ; scanner.Scan(); result.lines++ {
+		var err error
+		if useLexer {
+			err = lex.parseUser(scanner.Bytes(), user)
+		} else {
+			*user = User{Browsers: user.Browsers[:0]}
+			err = user.UnmarshalJSON(scanner.Bytes())
+		}
 
-		err := user.UnmarshalJSON(bytes)
 		if err != nil {
-			fmt.Printf("error: %v", err)
-			return
+			return nil, fmt.Errorf("line %d: %w", result.lines, err)
 		}
 
 		for _, browser := range user.Browsers {
-			if strings.Contains(browser, "Android") {
-				isAndroid = true
-			} else if strings.Contains(browser, "MSIE") {
-				isMSIE = true
-			} else {
-				continue
-			}
-
-			if _, exists := browsers[browser]; !exists {
-				browsers[browser] = true
+			if !result.browsers[browser] && query.Seen(browser) {
+				result.browsers[keep(browser)] = true
 			}
 		}
 
-		if !(isAndroid && isMSIE) {
-			continue
+		if query.Match(user) {
+			result.users = append(result.users, foundUser{index: result.lines, name: keep(user.Name), email: keep(user.Email)})
 		}
-
-		email := strings.Replace(user.Email, "@", " [at] ", 1)
-		users = append(users, fmt.Sprintf("[%d] %s <%s>\n", counter, user.Name, email))
 	}
 
-	_, err = fmt.Fprintln(out, "found users:\n"+strings.Join(users, ""))
-	if err != nil {
-		fmt.Printf("error: %v", err)
-		return
+	return result, scanner.Err()
+}
+
+func (f *found) write(out io.Writer) error {
+	users := &strings.Builder{}
+
+	for _, user := range f.users {
+		email := strings.Replace(user.email, "@", " [at] ", 1)
+		fmt.Fprintf(users, "[%d] %s <%s>\n", user.index, user.name, email)
 	}
 
-	_, err = fmt.Fprintln(out, "Total unique browsers", len(browsers))
+	_, err := fmt.Fprintln(out, "found users:\n"+users.String())
 	if err != nil {
-		fmt.Printf("error: %v", err)
-		return
+		return err
 	}
+
+	_, err = fmt.Fprintln(out, "Total unique browsers", len(f.browsers))
+
+	return err
 }
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// lexer pulls the browsers, the email and the name of the user out of a JSON line
+// without allocating. The strings of the user point into the line or into the buffer
+// of the lexer, so they are valid only until the next line is parsed.
+type lexer struct {
+	data []byte
+	pos  int
+	buf  []byte // Unescaped strings of the line.
+}
+
+var errSyntax = errors.New("syntax error")
+
+// parseUser fills the user from the line, the browsers slice is reused.
+func (l *lexer) parseUser(line []byte, user *User) error {
+	l.data, l.pos, l.buf = line, 0, l.buf[:0]
+	*user = User{Browsers: user.Browsers[:0]}
+
+	if err := l.parseObject(user); err != nil {
+		return fmt.Errorf("%w at byte %d", err, l.pos)
+	}
+
+	if l.skipSpaces(); l.pos != len(l.data) {
+		return fmt.Errorf("%w: data after the object at byte %d", errSyntax, l.pos)
+	}
+
+	return nil
+}
+
+func (l *lexer) parseObject(user *User) error {
+	if !l.consume('{') {
+		return errSyntax
+	}
+
+	if l.consume('}') {
+		return nil
+	}
+
+	for {
+		key, err := l.parseString()
+		if err != nil {
+			return err
+		}
+
+		if !l.consume(':') {
+			return errSyntax
+		}
+
+		switch {
+		case l.null():
+		case key == "browsers":
+			err = l.parseBrowsers(user)
+		case key == "email":
+			user.Email, err = l.parseString()
+		case key == "name":
+			user.Name, err = l.parseString()
+		default:
+			err = l.skipValue()
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if l.consume('}') {
+			return nil
+		}
+
+		if !l.consume(',') {
+			return errSyntax
+		}
+	}
+}
+
+func (l *lexer) parseBrowsers(user *User) error {
+	if !l.consume('[') {
+		return errSyntax
+	}
+
+	if l.consume(']') {
+		return nil
+	}
+
+	for {
+		browser, err := l.parseString()
+		if err != nil {
+			return err
+		}
+
+		user.Browsers = append(user.Browsers, browser)
+
+		if l.consume(']') {
+			return nil
+		}
+
+		if !l.consume(',') {
+			return errSyntax
+		}
+	}
+}
+
+// parseString returns the string pointing into the line if it has no escapes,
+// or into the buffer of the lexer otherwise.
+func (l *lexer) parseString() (string, error) {
+	if !l.consume('"') {
+		return "", errSyntax
+	}
+
+	start := l.pos
+	for ; l.pos < len(l.data); l.pos++ {
+		switch l.data[l.pos] {
+		case '"':
+			l.pos++
+			return unsafeString(l.data[start : l.pos-1]), nil
+		case '\\':
+			return l.parseEscaped(start)
+		}
+	}
+
+	return "", errSyntax
+}
+
+// parseEscaped unescapes the string started at the start into the buffer.
+func (l *lexer) parseEscaped(start int) (string, error) {
+	begin := len(l.buf)
+	l.buf = append(l.buf, l.data[start:l.pos]...)
+
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+
+		switch {
+		case c == '"':
+			// The strings returned before still point into the old array if append moves it.
+			return unsafeString(l.buf[begin:]), nil
+
+		case c != '\\':
+			l.buf = append(l.buf, c)
+
+		case l.pos >= len(l.data):
+			return "", errSyntax
+
+		default:
+			c = l.data[l.pos]
+			l.pos++
+
+			switch c {
+			case '"', '\\', '/':
+				l.buf = append(l.buf, c)
+			case 'b':
+				l.buf = append(l.buf, '\b')
+			case 'f':
+				l.buf = append(l.buf, '\f')
+			case 'n':
+				l.buf = append(l.buf, '\n')
+			case 'r':
+				l.buf = append(l.buf, '\r')
+			case 't':
+				l.buf = append(l.buf, '\t')
+			case 'u':
+				r, ok := l.parseRune()
+				if !ok {
+					return "", errSyntax
+				}
+
+				l.buf = utf8.AppendRune(l.buf, r)
+			default:
+				return "", errSyntax
+			}
+		}
+	}
+
+	return "", errSyntax
+}
+
+// parseRune parses the hex digits of \u, the surrogate pair is two of them.
+func (l *lexer) parseRune() (rune, bool) {
+	r, ok := l.parseHex()
+	if !ok || !utf16.IsSurrogate(r) {
+		return r, ok
+	}
+
+	if l.pos+1 < len(l.data) && l.data[l.pos] == '\\' && l.data[l.pos+1] == 'u' {
+		l.pos += 2
+
+		r2, ok := l.parseHex()
+		if !ok {
+			return r, false
+		}
+
+		if pair := utf16.DecodeRune(r, r2); pair != utf8.RuneError {
+			return pair, true
+		}
+	}
+
+	return utf8.RuneError, true
+}
+
+func (l *lexer) parseHex() (rune, bool) {
+	if l.pos+4 > len(l.data) {
+		return 0, false
+	}
+
+	r, err := strconv.ParseUint(unsafeString(l.data[l.pos:l.pos+4]), 16, 16)
+	l.pos += 4
+
+	return rune(r), err == nil
+}
+
+// skipValue skips the value of any type, it isn't validated in depth.
+func (l *lexer) skipValue() error {
+	l.skipSpaces()
+
+	if l.pos >= len(l.data) {
+		return errSyntax
+	}
+
+	switch l.data[l.pos] {
+	case '"':
+		_, err := l.parseString()
+		return err
+
+	case '{', '[':
+		depth := 0
+
+		for ; l.pos < len(l.data); l.pos++ {
+			switch l.data[l.pos] {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			case '"':
+				if _, err := l.parseString(); err != nil {
+					return err
+				}
+				l.pos-- // The closing quote is skipped by the loop.
+			}
+
+			if depth == 0 {
+				l.pos++
+				return nil
+			}
+		}
+
+		return errSyntax
+
+	default:
+		// A number, true, false or null.
+		start := l.pos
+		for l.pos < len(l.data) && !isDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+
+		if l.pos == start {
+			return errSyntax
+		}
+
+		return nil
+	}
+}
+
+// null skips the null value.
+func (l *lexer) null() bool {
+	l.skipSpaces()
+
+	if l.pos+4 <= len(l.data) && string(l.data[l.pos:l.pos+4]) == "null" {
+		l.pos += 4
+		return true
+	}
+
+	return false
+}
+
+// consume skips the spaces and the byte c if it's the next one.
+func (l *lexer) consume(c byte) bool {
+	l.skipSpaces()
+
+	if l.pos < len(l.data) && l.data[l.pos] == c {
+		l.pos++
+		return true
+	}
+
+	return false
+}
+
+func (l *lexer) skipSpaces() {
+	for l.pos < len(l.data) && isSpace(l.data[l.pos]) {
+		l.pos++
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDelimiter(c byte) bool {
+	return isSpace(c) || c == ',' || c == '}' || c == ']'
+}
+
+// unsafeString makes the string of the bytes without copying them.
+func unsafeString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
@@ -2,8 +2,17 @@ package main
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 // запускаем перед основными функциями по разу чтобы файл остался в памяти в файловом кеше
@@ -44,3 +53,323 @@ func BenchmarkFast(b *testing.B) {
 		FastSearch(ioutil.Discard)
 	}
 }
+
+func BenchmarkFastLexer(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		FastSearchLexer(ioutil.Discard)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	queries := map[string]func(user *User) bool{
+		`email endswith ".gov"`: func(user *User) bool {
+			return strings.HasSuffix(user.Email, ".gov")
+		},
+		`NOT (name startswith "A" or name startswith "B") and browsers is "LG-LX550 AU-MIC-LX550/2.0 MMP/2.0 Profile/MIDP-2.0 Configuration/CLDC-1.1"`: func(user *User) bool {
+			if strings.HasPrefix(user.Name, "A") || strings.HasPrefix(user.Name, "B") {
+				return false
+			}
+			for _, browser := range user.Browsers {
+				if browser == "LG-LX550 AU-MIC-LX550/2.0 MMP/2.0 Profile/MIDP-2.0 Configuration/CLDC-1.1" {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for query, match := range queries {
+		expected := "found users:\n"
+		for i, line := range strings.Split(string(data), "\n") {
+			user := &User{}
+			if err := json.Unmarshal([]byte(line), user); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if match(user) {
+				expected += fmt.Sprintf("[%d] %s <%s>\n", i, user.Name, strings.Replace(user.Email, "@", " [at] ", 1))
+			}
+		}
+
+		if expected == "found users:\n" {
+			t.Fatalf("no users match %s, the test is useless", query)
+		}
+
+		out := new(bytes.Buffer)
+		if err := Search(out, filePath, MustParseQuery(query)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		result := out.String()[:strings.Index(out.String(), "\nTotal")]
+		if result != expected {
+			t.Errorf("%s results not match\nGot:\n%v\nExpected:\n%v", query, result, expected)
+		}
+	}
+
+	for _, query := range []string{
+		`email`,
+		`email has "x"`,
+		`phone is "x"`,
+		`email is x`,
+		`email is "x`,
+		`(email is "x"`,
+		`email is "x" or`,
+		`email is "x" name is "y"`,
+	} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("no error for the query %s", query)
+		}
+	}
+}
+
+func TestSearchParallel(t *testing.T) {
+	for _, query := range []*Query{androidAndMSIE, MustParseQuery(`email endswith ".gov"`)} {
+		expected := new(bytes.Buffer)
+		if err := Search(expected, filePath, query); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// More workers than the lines of the file are fine too.
+		for _, workers := range []int{0, 1, 2, 3, 7, 64, 5000} {
+			out := new(bytes.Buffer)
+			if err := SearchParallel(out, filePath, query, workers); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if out.String() != expected.String() {
+				t.Errorf("%d workers results not match\nGot:\n%v\nExpected:\n%v", workers, out, expected)
+			}
+		}
+	}
+}
+
+func TestChunkBounds(t *testing.T) {
+	data := "a\nbb\nccc\n\ndddd"
+	bounds, err := chunkBounds(strings.NewReader(data), int64(len(data)), 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := fmt.Sprint(bounds)
+	if expected := "[0 5 9 10 14]"; result != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+}
+
+func BenchmarkFastParallel(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = SearchParallel(ioutil.Discard, filePath, androidAndMSIE, 0)
+	}
+}
+
+func BenchmarkFastParallel4(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = SearchParallel(ioutil.Discard, filePath, androidAndMSIE, 4)
+	}
+}
+
+func TestLexer(t *testing.T) {
+	slowOut := new(bytes.Buffer)
+	SlowSearch(slowOut)
+
+	lexerOut := new(bytes.Buffer)
+	FastSearchLexer(lexerOut)
+
+	if lexerOut.String() != slowOut.String() {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", lexerOut, slowOut)
+	}
+
+	lines := []string{
+		`{"browsers":["a\"b","\u00e9\ud83d\ude00\n"],"email":"x@y","name":"\/n"}`,
+		` { "skip" : {"a":[1,{"b":"]}"}]}, "n":-1.5e3, "t":true , "name" : null, "browsers" : null, "email":"e" } `,
+		`{"browsers":[],"name":"","other":[[],{}]}`,
+		`{}`,
+	}
+
+	lex := &lexer{}
+	user := &User{}
+
+	for _, line := range lines {
+		expected := &User{}
+		if err := json.Unmarshal([]byte(line), expected); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := lex.parseUser([]byte(line), user); err != nil {
+			t.Errorf("%s unexpected error: %v", line, err)
+			continue
+		}
+
+		got, want := fmt.Sprintf("%q %q %q", user.Browsers, user.Email, user.Name), fmt.Sprintf("%q %q %q", expected.Browsers, expected.Email, expected.Name)
+		if got != want {
+			t.Errorf("%s results not match\nGot: %v\nExpected: %v", line, got, want)
+		}
+	}
+
+	for _, line := range []string{``, `{`, `{"name":}`, `{"name":"a"`, `{"name":"a",}`, `{"name":"\x"}`, `{"name":"\u12"}`, `{"a":[1}`, `{} {}`} {
+		if err := lex.parseUser([]byte(line), user); err == nil {
+			t.Errorf("no error for the line %s", line)
+		}
+	}
+}
+
+func TestCompressedInput(t *testing.T) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "hw3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	gzipped := new(bytes.Buffer)
+	gzipWriter := gzip.NewWriter(gzipped)
+	gzipWriter.Write(data)
+	gzipWriter.Close()
+
+	zstdWriter, _ := zstd.NewWriter(nil)
+	compressed := map[string][]byte{
+		"users.txt.gz":  gzipped.Bytes(),
+		"users.txt.zst": zstdWriter.EncodeAll(data, nil),
+		// The format is detected by the contents, not by the extension.
+		"users.dump": gzipped.Bytes(),
+	}
+
+	expected := new(bytes.Buffer)
+	if err := SlowSearch(expected); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, contents := range compressed {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, contents, 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		searches := map[string]func(out io.Writer) error{
+			"slow": func(out io.Writer) error { return SlowSearchFile(out, path) },
+			"fast": func(out io.Writer) error { return Search(out, path, androidAndMSIE) },
+			"lexer": func(out io.Writer) error {
+				return SearchReaderLexer(out, bytes.NewReader(contents), androidAndMSIE)
+			},
+			"parallel": func(out io.Writer) error { return SearchParallel(out, path, androidAndMSIE, 4) },
+		}
+
+		for search, run := range searches {
+			out := new(bytes.Buffer)
+			if err := run(out); err != nil {
+				t.Errorf("%s %s unexpected error: %v", search, name, err)
+				continue
+			}
+
+			if out.String() != expected.String() {
+				t.Errorf("%s %s results not match\nGot:\n%v\nExpected:\n%v", search, name, out, expected)
+			}
+		}
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	if err := Search(ioutil.Discard, "./data/no-such-file.txt", androidAndMSIE); !os.IsNotExist(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := SlowSearchFile(ioutil.Discard, "./data/no-such-file.txt"); !os.IsNotExist(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	broken := "{\"name\":\"a\"}\n{\"name\":"
+	searches := map[string]func() error{
+		"slow":  func() error { return SlowSearchReader(ioutil.Discard, strings.NewReader(broken)) },
+		"fast":  func() error { return SearchReader(ioutil.Discard, strings.NewReader(broken), androidAndMSIE) },
+		"lexer": func() error { return SearchReaderLexer(ioutil.Discard, strings.NewReader(broken), androidAndMSIE) },
+		"gzip": func() error {
+			return SearchReader(ioutil.Discard, bytes.NewReader([]byte{0x1f, 0x8b, 0}), androidAndMSIE)
+		},
+	}
+
+	for search, run := range searches {
+		if err := run(); err == nil {
+			t.Errorf("%s no error for the broken input", search)
+		}
+	}
+}
+
+func TestParseUserAgent(t *testing.T) {
+	testCases := map[string]Agent{
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/41.0.2227.0 Safari/537.36":    {"Chrome", "41", "Linux"},
+		"Mozilla/5.0 (compatible; MSIE 10.0; Windows Phone 8.0; Trident/6.0; IEMobile/10.0; ARM; Touch)":             {"MSIE", "10", "Windows Phone"},
+		"Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; MATBJS; rv:11.0) like Gecko":                              {"MSIE", "11", "Windows"},
+		"Mozilla/5.0 (Android; Linux armv7l; rv:10.0.1) Gecko/20100101 Firefox/10.0.1 Fennec/10.0.1":                 {"Firefox", "10", "Android"},
+		"Mozilla/5.0 (Linux; U; Android 2.2; en-us; Nexus One Build/FRF91) AppleWebKit/533.1 Version/4.0":            {"Android", "2", "Android"},
+		"Mozilla/5.0 (iPad; CPU OS 6_0 like Mac OS X) AppleWebKit/536.26 Version/6.0 Mobile/10A5355d Safari/8536.25": {"Safari", "6", "iOS"},
+		"Opera/9.80 (J2ME/MIDP; Opera Mini/9.80 (S60; SymbOS; Opera Mobi/23.348; U; en) Presto/2.5.25 Version/10.54": {"Opera", "10", "J2ME"},
+		"Baiduspider ( http://www.baidu.com/search/spider.htm)":                                                      {"Bot", "", "Other"},
+		"LG-LX550 AU-MIC-LX550/2.0 MMP/2.0 Profile/MIDP-2.0 Configuration/CLDC-1.1":                                  {"Other", "", "J2ME"},
+	}
+
+	for agent, expected := range testCases {
+		if result := ParseUserAgent(agent); result != expected {
+			t.Errorf("%s results not match\nGot: %v\nExpected: %v", agent, result, expected)
+		}
+	}
+}
+
+func TestBrowserReport(t *testing.T) {
+	data := strings.Join([]string{
+		`{"browsers":["Mozilla/5.0 (Windows NT 6.1) Chrome/41.0 Safari/537.36","Mozilla/5.0 (X11; Linux) Chrome/41.0 Safari/537.36"],"name":"a"}`,
+		`{"browsers":["Mozilla/4.0 (compatible; MSIE 6.0; Windows NT 5.1)","Mozilla/5.0 (Macintosh) Chrome/52.0 Safari/537.36"],"name":"b"}`,
+		`{"browsers":["Mozilla/4.0 (compatible; MSIE 7.0; Windows NT 6.0)"],"name":"c"}`,
+		`{"browsers":["curl/7.9.8"],"name":"d"}`,
+	}, "\n")
+
+	report, err := BrowserReport(strings.NewReader(data), nil, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	table := new(bytes.Buffer)
+	if err := WriteReport(table, report, "table"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedTable := `FAMILY  USERS  SHARE  VERSIONS      OS
+Chrome  2      50.0%  41: 1, 52: 1  Linux: 1, Windows: 1
+MSIE    2      50.0%  6: 1, 7: 1    Windows: 2
+total   4
+`
+	if table.String() != expectedTable {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", table, expectedTable)
+	}
+
+	report, err = BrowserReport(strings.NewReader(data), MustParseQuery(`name is "d"`), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := new(bytes.Buffer)
+	if err := WriteReport(out, report, "json"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	decoded := &Report{}
+	if err := json.Unmarshal(out.Bytes(), decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	result := fmt.Sprint(*decoded)
+	if expected := "{1 [{Other 1 1 [{ 1}] [{Other 1}]}]}"; result != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", result, expected)
+	}
+
+	if err := WriteReport(out, report, "xml"); err == nil {
+		t.Errorf("no error for an unknown format")
+	}
+}
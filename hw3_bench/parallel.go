@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// SearchParallel is the same as Search, but the file is split into the chunks
+// of whole lines which are parsed by the workers at once, NumCPU of them by default.
+// The output is exactly the same as the one of Search. The compressed files
+// can't be split, so they are searched in a single goroutine.
+func SearchParallel(out io.Writer, path string, query *Query, workers int) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	compressed, err := isCompressed(file)
+	if err != nil {
+		return err
+	}
+
+	if compressed {
+		return searchTo(out, file, query, false)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	bounds, err := chunkBounds(file, info.Size(), workers)
+	if err != nil {
+		return err
+	}
+
+	results := make([]*found, len(bounds)-1)
+	errs := make([]error, len(bounds)-1)
+	wg := &sync.WaitGroup{}
+
+	for i := range results {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			chunk := io.NewSectionReader(file, bounds[i], bounds[i+1]-bounds[i])
+			results[i], errs[i] = searchReader(chunk, query, false)
+		}(i)
+	}
+
+	wg.Wait()
+
+	merged := &found{browsers: map[string]bool{}}
+
+	for i, result := range results {
+		if errs[i] != nil {
+			return fmt.Errorf("chunk at byte %d: %w", bounds[i], errs[i])
+		}
+
+		for _, user := range result.users {
+			user.index += merged.lines
+			merged.users = append(merged.users, user)
+		}
+
+		for browser := range result.browsers {
+			merged.browsers[browser] = true
+		}
+
+		merged.lines += result.lines
+	}
+
+	return merged.write(out)
+}
+
+// chunkBounds splits the file into n chunks of about the same size,
+// every chunk but the last one ends right after a new line.
+// The bounds of the i-th chunk are bounds[i] and bounds[i+1].
+func chunkBounds(r io.ReaderAt, size int64, n int) ([]int64, error) {
+	bounds := []int64{0}
+
+	for i := 1; i < n; i++ {
+		offset := size * int64(i) / int64(n)
+		if prev := bounds[len(bounds)-1]; offset <= prev {
+			continue
+		}
+
+		start, err := lineStart(r, offset, size)
+		if err != nil {
+			return nil, err
+		}
+
+		if start > bounds[len(bounds)-1] && start < size {
+			bounds = append(bounds, start)
+		}
+	}
+
+	return append(bounds, size), nil
+}
+
+// lineStart finds the start of the first line at the offset or after it.
+func lineStart(r io.ReaderAt, offset, size int64) (int64, error) {
+	buf := make([]byte, 4096)
+
+	// The line starts at the offset if the byte before it is the new line.
+	for pos := offset - 1; pos < size; pos += int64(len(buf)) {
+		n, err := r.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return size, nil
+}
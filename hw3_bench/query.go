@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query is a compiled filter of the users, e.g.
+//
+//	browsers contains "Android" and browsers contains "MSIE"
+//	email endswith "@mail.ru" or not (name startswith "A")
+//
+// The fields are browsers, email and name, a condition on browsers is true
+// when it's true for any of them. The operators are contains, startswith,
+// endswith and is, which compares the whole value.
+type Query struct {
+	match func(user *User) bool
+	// browsers are the conditions on the browsers, the ones matching any of them are counted as seen.
+	browsers []func(browser string) bool
+}
+
+// Match tells whether the user passes the filter.
+func (q *Query) Match(user *User) bool {
+	return q.match(user)
+}
+
+// Seen tells whether the browser matches any condition on the browsers.
+func (q *Query) Seen(browser string) bool {
+	for _, test := range q.browsers {
+		if test(browser) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// operators compare the value of a field with the string of the query.
+var operators = map[string]func(value, str string) bool{
+	"contains":   strings.Contains,
+	"startswith": strings.HasPrefix,
+	"endswith":   strings.HasSuffix,
+	"is": func(value, str string) bool {
+		return value == str
+	},
+}
+
+// fields get the single value fields of the user, browsers are the list one.
+var fields = map[string]func(user *User) string{
+	"email": func(user *User) string { return user.Email },
+	"name":  func(user *User) string { return user.Name },
+}
+
+// ParseQuery compiles the query:
+//
+//	expr      = and { "or" and }
+//	and       = not { "and" not }
+//	not       = "not" not | "(" expr ")" | condition
+//	condition = field operator string
+//
+// The strings are quoted the Go way.
+func ParseQuery(query string) (*Query, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, query: &Query{}}
+
+	p.query.match, err = p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at the end of the query", p.tokens[p.pos])
+	}
+
+	return p.query, nil
+}
+
+// MustParseQuery is the same as ParseQuery, but it panics on the errors.
+func MustParseQuery(query string) *Query {
+	q, err := ParseQuery(query)
+	if err != nil {
+		panic(err)
+	}
+
+	return q
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+	query  *Query
+}
+
+// next returns the next token, it's empty at the end of the query.
+func (p *parser) next() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+
+	p.pos++
+
+	return p.tokens[p.pos-1]
+}
+
+// peek tells whether the next token is the keyword, it's skipped then.
+func (p *parser) peek(keyword string) bool {
+	if p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], keyword) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) parseOr() (func(*User) bool, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = or(left, right)
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (func(*User) bool, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = and(left, right)
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (func(*User) bool, error) {
+	if p.peek("not") {
+		match, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(user *User) bool {
+			return !match(user)
+		}, nil
+	}
+
+	if p.peek("(") {
+		match, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.peek(")") {
+			return nil, fmt.Errorf("expected ) instead of %q", p.next())
+		}
+
+		return match, nil
+	}
+
+	return p.parseCondition()
+}
+
+func (p *parser) parseCondition() (func(*User) bool, error) {
+	field, operator, str := strings.ToLower(p.next()), strings.ToLower(p.next()), p.next()
+
+	compare, ok := operators[operator]
+	if !ok {
+		return nil, fmt.Errorf("unknown operator %q", operator)
+	}
+
+	if !strings.HasPrefix(str, `"`) {
+		return nil, fmt.Errorf("expected a quoted string instead of %q", str)
+	}
+
+	str, err := strconv.Unquote(str)
+	if err != nil {
+		return nil, fmt.Errorf("bad string: %w", err)
+	}
+
+	if field == "browsers" {
+		test := func(browser string) bool {
+			return compare(browser, str)
+		}
+		p.query.browsers = append(p.query.browsers, test)
+
+		return func(user *User) bool {
+			for _, browser := range user.Browsers {
+				if test(browser) {
+					return true
+				}
+			}
+
+			return false
+		}, nil
+	}
+
+	get, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+
+	return func(user *User) bool {
+		return compare(get(user), str)
+	}, nil
+}
+
+func and(left, right func(*User) bool) func(*User) bool {
+	return func(user *User) bool {
+		return left(user) && right(user)
+	}
+}
+
+func or(left, right func(*User) bool) func(*User) bool {
+	return func(user *User) bool {
+		return left(user) || right(user)
+	}
+}
+
+// tokenize splits the query into the words, the parentheses and the quoted strings.
+func tokenize(query string) ([]string, error) {
+	tokens := make([]string, 0)
+
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '(' || c == ')':
+			tokens = append(tokens, query[i:i+1])
+			i++
+
+		case c == '"':
+			end := i + 1
+			for end < len(query) && query[end] != '"' {
+				if query[end] == '\\' {
+					end++
+				}
+				end++
+			}
+
+			if end >= len(query) {
+				return nil, fmt.Errorf("unterminated string %s", query[i:])
+			}
+
+			tokens = append(tokens, query[i:end+1])
+			i = end + 1
+
+		default:
+			end := i
+			for end < len(query) && !strings.ContainsRune(" \t\n\r()\"", rune(query[end])) {
+				end++
+			}
+
+			tokens = append(tokens, query[i:end])
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
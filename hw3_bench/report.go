@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Agent is the browser of a user agent string.
+type Agent struct {
+	Family  string // E.g. Chrome.
+	Version string // Major version, empty if it's unknown.
+	OS      string
+}
+
+// agentRule detects the browser family by the tokens of the user agent,
+// the version is the number right after the version token.
+type agentRule struct {
+	family  string
+	tokens  []string
+	version string
+}
+
+// agentRules are checked in order, e.g. Chrome goes before Safari
+// because the user agent of Chrome has Safari in it too.
+var agentRules = []agentRule{
+	{"Bot", []string{"bot", "Bot", "spider", "Spider", "crawler", "Crawler"}, ""},
+	{"Edge", []string{"Edge/"}, "Edge/"},
+	{"Opera", []string{"OPR/"}, "OPR/"},
+	{"Opera", []string{"Opera"}, "Version/"},
+	{"Opera", []string{"Opera"}, "Opera/"},
+	{"Opera", []string{"Opera"}, "Opera "},
+	{"Chrome", []string{"CriOS/"}, "CriOS/"},
+	{"Chrome", []string{"Chrome/"}, "Chrome/"},
+	{"Firefox", []string{"Firefox/"}, "Firefox/"},
+	{"MSIE", []string{"MSIE "}, "MSIE "},
+	{"MSIE", []string{"Trident/"}, "rv:"},
+	{"Android", []string{"Android"}, "Android "},
+	{"Safari", []string{"Safari/"}, "Version/"},
+	{"BlackBerry", []string{"BlackBerry"}, "/"},
+}
+
+// osRules detect the operating system, the first matching one wins.
+var osRules = []struct {
+	os     string
+	tokens []string
+}{
+	{"Windows Phone", []string{"Windows Phone"}},
+	{"Windows", []string{"Windows"}},
+	{"Android", []string{"Android"}},
+	{"iOS", []string{"iPhone", "iPad", "iPod"}},
+	{"Chrome OS", []string{"CrOS"}},
+	{"macOS", []string{"Mac OS X", "Macintosh"}},
+	{"Linux", []string{"Linux"}},
+	{"BSD", []string{"BSD"}},
+	{"BlackBerry", []string{"BlackBerry"}},
+	{"J2ME", []string{"MIDP", "J2ME"}},
+}
+
+// ParseUserAgent detects the browser family, its major version and the OS of the user agent,
+// the family and the OS are Other when they aren't known. The version points into the agent.
+func ParseUserAgent(agent string) Agent {
+	result := Agent{Family: "Other", OS: "Other"}
+
+	for _, rule := range agentRules {
+		if !containsAny(agent, rule.tokens) {
+			continue
+		}
+
+		if rule.version == "" {
+			result.Family = rule.family
+			break
+		}
+
+		if i := strings.Index(agent, rule.version); i >= 0 {
+			result.Family = rule.family
+			result.Version = leadingNumber(agent[i+len(rule.version):])
+			break
+		}
+	}
+
+	for _, rule := range osRules {
+		if containsAny(agent, rule.tokens) {
+			result.OS = rule.os
+			break
+		}
+	}
+
+	return result
+}
+
+func containsAny(str string, tokens []string) bool {
+	for _, token := range tokens {
+		if strings.Contains(str, token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// leadingNumber returns the digits the string starts with.
+func leadingNumber(str string) string {
+	end := 0
+	for end < len(str) && str[end] >= '0' && str[end] <= '9' {
+		end++
+	}
+
+	return str[:end]
+}
+
+// Report is the statistics of the browsers of the users.
+type Report struct {
+	Users    int           `json:"users"`
+	Families []FamilyStats `json:"families"`
+}
+
+// FamilyStats are the users of the family, a user is counted once
+// even if it has several browsers of the same family, version or OS.
+type FamilyStats struct {
+	Family   string  `json:"family"`
+	Users    int     `json:"users"`
+	Share    float64 `json:"share"` // Part of all the users, from 0 to 1.
+	Versions []Count `json:"versions"`
+	OS       []Count `json:"os"`
+}
+
+// Count is the number of the users of a version or an OS.
+type Count struct {
+	Name  string `json:"name"`
+	Users int    `json:"users"`
+}
+
+type familyCounts struct {
+	users    int
+	versions map[string]int
+	os       map[string]int
+}
+
+// BrowserReport counts the browsers of the users matching the query, nil query matches all of them.
+// Only the top families are listed, each with its top versions and OSes; zero or less top means all.
+// The gzip and zstd input is decompressed on the fly.
+func BrowserReport(r io.Reader, query *Query, top int) (*Report, error) {
+	input, err := Decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	scanner := bufio.NewScanner(input)
+	families := map[string]*familyCounts{}
+	report := &Report{}
+	lex := &lexer{}
+	user := &User{}
+
+	// Keys of the families, the versions and the OSes the current user is counted for.
+	seen := make([]string, 0)
+
+	for line := 0; scanner.Scan(); line++ {
+		if err := lex.parseUser(scanner.Bytes(), user); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if query != nil && !query.Match(user) {
+			continue
+		}
+
+		report.Users++
+		seen = seen[:0]
+
+		for _, browser := range user.Browsers {
+			agent := ParseUserAgent(browser)
+
+			counts, ok := families[agent.Family]
+			if !ok {
+				counts = &familyCounts{versions: map[string]int{}, os: map[string]int{}}
+				families[agent.Family] = counts
+			}
+
+			if !contains(seen, agent.Family) {
+				seen = append(seen, agent.Family)
+				counts.users++
+			}
+
+			if versionKey := agent.Family + " " + agent.Version; !contains(seen, versionKey) {
+				seen = append(seen, versionKey)
+				// The version points into the line of the lexer, so it's copied to be kept.
+				counts.versions[strings.Clone(agent.Version)]++
+			}
+
+			if osKey := agent.Family + "\x00" + agent.OS; !contains(seen, osKey) {
+				seen = append(seen, osKey)
+				counts.os[agent.OS]++
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for family, counts := range families {
+		stats := FamilyStats{
+			Family:   family,
+			Users:    counts.users,
+			Versions: topCounts(counts.versions, top),
+			OS:       topCounts(counts.os, top),
+		}
+
+		if report.Users > 0 {
+			stats.Share = float64(counts.users) / float64(report.Users)
+		}
+
+		report.Families = append(report.Families, stats)
+	}
+
+	sort.Slice(report.Families, func(i, j int) bool {
+		a, b := report.Families[i], report.Families[j]
+		return a.Users > b.Users || a.Users == b.Users && a.Family < b.Family
+	})
+
+	if top > 0 && len(report.Families) > top {
+		report.Families = report.Families[:top]
+	}
+
+	return report, nil
+}
+
+// BrowserReportFile is BrowserReport of the file.
+func BrowserReportFile(path string, query *Query, top int) (*Report, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return BrowserReport(file, query, top)
+}
+
+func contains(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+
+	return false
+}
+
+// topCounts sorts the counts from the largest one and keeps the top of them.
+func topCounts(counts map[string]int, top int) []Count {
+	result := make([]Count, 0, len(counts))
+	for name, users := range counts {
+		result = append(result, Count{Name: name, Users: users})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Users > result[j].Users || result[i].Users == result[j].Users && result[i].Name < result[j].Name
+	})
+
+	if top > 0 && len(result) > top {
+		result = result[:top]
+	}
+
+	return result
+}
+
+// ReportFormats write the report by the names of the formats.
+var ReportFormats = map[string]func(out io.Writer, report *Report) error{
+	"table": WriteReportTable,
+	"json":  WriteReportJSON,
+}
+
+// WriteReport writes the report in the format, one of ReportFormats.
+func WriteReport(out io.Writer, report *Report, format string) error {
+	write, ok := ReportFormats[format]
+	if !ok {
+		return fmt.Errorf("unknown report format %q", format)
+	}
+
+	return write(out, report)
+}
+
+// WriteReportTable writes a row per family, the unknown versions are marked by "?".
+func WriteReportTable(out io.Writer, report *Report) error {
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(table, "FAMILY\tUSERS\tSHARE\tVERSIONS\tOS")
+
+	for _, family := range report.Families {
+		fmt.Fprintf(table, "%s\t%d\t%.1f%%\t%s\t%s\n", family.Family, family.Users, family.Share*100,
+			joinCounts(family.Versions), joinCounts(family.OS))
+	}
+
+	fmt.Fprintf(table, "total\t%d\n", report.Users)
+
+	return table.Flush()
+}
+
+func joinCounts(counts []Count) string {
+	parts := make([]string, 0, len(counts))
+
+	for _, count := range counts {
+		name := count.Name
+		if name == "" {
+			name = "?"
+		}
+
+		parts = append(parts, fmt.Sprintf("%s: %d", name, count.Users))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// WriteReportJSON writes the report as an indented JSON object.
+func WriteReportJSON(out io.Writer, report *Report) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}